@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Violation is an audit check a hand failed. it is logged as one JSON line
+// (and kept in audit.jsonl when the room has a store) and freezes the table
+type Violation struct {
+	Room   int       `json:"room"`
+	Hand   string    `json:"hand"`
+	Check  string    `json:"check"` // "chips", "stacks", "action_order" or "cards"
+	Detail string    `json:"detail"`
+	Step   int       `json:"step"` // events in the hand's log when it was caught
+	Time   time.Time `json:"time"`
+}
+
+// a frozen table takes no more actions, joins, leaves or hands until the server is restarted.
+// the hand that broke is never settled, so restarting puts everyone back to their stacks before it
+var errTableFrozen = errors.New("table is frozen after an audit failure")
+
+// auditHand checks the hand after anything moved it on: chips add up, nobody's
+// stack or bet is negative, every action since the last audit came from the player
+// whose turn it was (anyone may fold, that's how leaving works), nobody acts after
+// folding or once the pots are being paid, and no card is in two places at once.
+// returns the first thing that broke, nil if the hand is fine
+func (r *Room) auditHand(h *Hand) *Violation {
+	fail := func(check, format string, args ...any) *Violation {
+		return &Violation{Room: r.id, Hand: h.id, Check: check, Detail: fmt.Sprintf(format, args...), Step: len(h.log), Time: time.Now()}
+	}
+
+	if err := checkChips(h); err != nil {
+		return fail("chips", "%v", err)
+	}
+	if h.pot < 0 {
+		return fail("stacks", "pot is %s", h.pot)
+	}
+	for _, p := range h.Players {
+		if p.Stack < 0 || p.streetBet < 0 || p.totalBet < 0 {
+			return fail("stacks", "%s has stack %s, bet %s, in the pot %s", p.ID, p.Stack, p.streetBet, p.totalBet)
+		}
+	}
+
+	folded := map[string]bool{}
+	paying := false
+	for i, e := range h.log {
+		if e.Type == EventBetReturned || e.Type == EventShowdown || e.Type == EventPotAwarded {
+			paying = true
+		}
+		if e.Type != EventAction || !contains(decisions, e.Action) {
+			continue
+		}
+		switch {
+		case paying:
+			return fail("action_order", "%s did %s after the showdown", e.PlayerID, e.Action)
+		case folded[e.PlayerID]:
+			return fail("action_order", "%s did %s after folding", e.PlayerID, e.Action)
+		case i >= r.auditSeen && e.Action != "fold" && e.PlayerID != r.auditDue:
+			return fail("action_order", "%s did %s when it was %q's turn", e.PlayerID, e.Action, r.auditDue)
+		}
+		if i >= r.auditSeen && e.PlayerID == r.auditDue {
+			r.auditDue = "" // one action per turn
+		}
+		if e.Action == "fold" {
+			folded[e.PlayerID] = true
+		}
+	}
+	r.auditSeen = len(h.log)
+	if h.currentState != StateOver {
+		r.auditDue = h.Players[h.actionPlayerIndex].ID
+	}
+
+	seen := map[Card]string{}
+	place := func(where string, cards []Card) *Violation {
+		for _, c := range cards {
+			if prev, ok := seen[c]; ok {
+				return fail("cards", "%s%s is in %s and %s", c.Rank, c.Suit, prev, where)
+			}
+			seen[c] = where
+		}
+		return nil
+	}
+	if v := place("the deck", h.deck); v != nil {
+		return v
+	}
+	if v := place("the board", h.board); v != nil {
+		return v
+	}
+	for _, p := range h.Players {
+		if v := place(p.ID+"'s hand", p.hand); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// freeze stops the table where it is, the hand is left as it was for whoever looks into it
+func (r *Room) freeze(v *Violation) {
+	r.stopClock()
+	r.frozen = v
+	b, _ := json.Marshal(v)
+	fmt.Printf("audit violation: %s\n", b)
+	if r.store != nil {
+		if err := r.store.appendJSONL("audit.jsonl", v); err != nil {
+			fmt.Printf("can't record audit violation: %v\n", err)
+		}
+	}
+	r.broadcast(Event{Type: EventTableFrozen, Reason: v.Check + ": " + v.Detail})
+}
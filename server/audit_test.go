@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// a whole hand with a player leaving out of turn, nothing to complain about
+func TestAuditPassesNormalHand(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3")
+	r.startNextHandIfReady()
+	if err := r.leave("3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := r.action(Action{PlayerID: id, Action: "call"}); err != nil {
+			t.Fatalf("%s call: %v", id, err)
+		}
+	}
+	for i := 0; i < 3 && r.currentHand != nil; i++ {
+		for _, id := range []string{"2", "1"} {
+			if err := r.action(Action{PlayerID: id, Action: "check"}); err != nil {
+				t.Fatalf("%s check: %v", id, err)
+			}
+		}
+	}
+	if r.frozen != nil || r.handCount != 2 {
+		t.Fatalf("frozen %+v after %d hands", r.frozen, r.handCount)
+	}
+}
+
+func TestAuditFreezesTable(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3")
+	st, err := openStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.store = st
+	c := listen(r)
+	r.startNextHandIfReady()
+
+	// a chip appears out of nowhere, the next action is where the audit sees it
+	r.currentHand.Players[1].Stack += Chip
+	if err := r.action(Action{PlayerID: "1", Action: "call"}); err != nil {
+		t.Fatal(err)
+	}
+	if r.frozen == nil || r.frozen.Check != "chips" || r.frozen.Hand != "1-1" {
+		t.Fatalf("frozen = %+v, want a chips violation in hand 1-1", r.frozen)
+	}
+	if e := received(c, EventTableFrozen); len(e) != 1 || !strings.HasPrefix(e[0].Reason, "chips: ") {
+		t.Fatalf("table_frozen events: %+v", e)
+	}
+
+	// nothing moves any more, but the table can still be looked at
+	if resp := r.handleCommand(Command{Kind: "action", Action: Action{PlayerID: "2", Action: "call"}}); !errors.Is(resp.Err, errTableFrozen) {
+		t.Fatalf("action on a frozen table: %v", resp.Err)
+	}
+	if resp := r.handleCommand(Command{Kind: "join", Player: newPlayer("4", "p4", 50*Chip)}); !errors.Is(resp.Err, errTableFrozen) {
+		t.Fatalf("join on a frozen table: %v", resp.Err)
+	}
+	if st := r.snapshot(""); st.Frozen == nil || st.Hand == nil || r.clock != nil {
+		t.Fatalf("frozen table state %+v", st)
+	}
+	r.startNextHandIfReady()
+	if r.handCount != 1 {
+		t.Fatalf("dealt hand %d on a frozen table", r.handCount)
+	}
+
+	var logged []Violation
+	err = st.readJSONL("audit.jsonl", func(line []byte) error {
+		var v Violation
+		logged = append(logged, v)
+		return json.Unmarshal(line, &logged[len(logged)-1])
+	})
+	if err != nil || len(logged) != 1 || logged[0].Check != "chips" {
+		t.Fatalf("audit.jsonl has %d violations (%v), want 1", len(logged), err)
+	}
+}
+
+func TestAuditChecks(t *testing.T) {
+	tests := []struct {
+		check  string
+		breaks func(r *Room, h *Hand)
+	}{
+		{"stacks", func(r *Room, h *Hand) {
+			// a stack goes negative, the chips are all still there
+			h.Players[0].Stack -= 101 * Chip
+			h.pot += 101 * Chip
+		}},
+		{"cards", func(r *Room, h *Hand) { h.Players[0].hand[0] = h.Players[1].hand[1] }},
+		{"cards", func(r *Room, h *Hand) { h.deck = append(h.deck, h.Players[2].hand[0]) }},
+		{"action_order", func(r *Room, h *Hand) {
+			// seat 2 is logged acting while seat 1 is to act
+			h.publishAction(1, "call", 0)
+		}},
+		{"action_order", func(r *Room, h *Hand) {
+			h.publishAction(0, "fold", 0)
+			h.log = append(h.log, h.log[len(h.log)-1])
+		}},
+	}
+	for _, tt := range tests {
+		r := seatedRoom(t, "1", "2", "3")
+		r.startNextHandIfReady()
+		tt.breaks(r, r.currentHand)
+		r.handChanged()
+		if r.frozen == nil || r.frozen.Check != tt.check {
+			t.Errorf("frozen = %+v, want a %s violation", r.frozen, tt.check)
+		}
+	}
+}
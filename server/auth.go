@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// how long a session token is good for
+const tokenTTL = 24 * time.Hour
+
+// what a session token says about its holder
+type Claims struct {
+	Room     int    `json:"room"`
+	PlayerID string `json:"playerId"`
+	Session  string `json:"session"` // random per join, the room keeps a copy on the player
+	Expires  int64  `json:"exp"`     // unix seconds
+}
+
+// the token is signed by us but isn't for the player's current seat
+var errBadSession = errors.New("session is not valid for this seat")
+
+// Auth signs and checks session tokens with a server secret
+type Auth struct {
+	secret []byte
+}
+
+// the secret comes from POKER_SECRET, or is random. with a store the random one is
+// kept there (see loadAuth) so tokens outlive the process
+func newAuth() *Auth {
+	if s := os.Getenv("POKER_SECRET"); s != "" {
+		return &Auth{secret: []byte(s)}
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return &Auth{secret: secret}
+}
+
+func (a *Auth) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue makes a new session for a player joining a room, token format is payload.signature
+func (a *Auth) issue(room int, playerID string) (string, Claims) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	c := Claims{
+		Room:     room,
+		PlayerID: playerID,
+		Session:  hex.EncodeToString(nonce),
+		Expires:  time.Now().Add(tokenTTL).Unix(),
+	}
+	b, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + a.sign(payload), c
+}
+
+// verify checks the token is ours, not expired, and belongs to this player in this room
+func (a *Auth) verify(token string, room int, playerID string) (Claims, error) {
+	var c Claims
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(a.sign(payload))) {
+		return c, fmt.Errorf("invalid token")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(b, &c) != nil {
+		return c, fmt.Errorf("invalid token")
+	}
+	if time.Now().Unix() > c.Expires {
+		return c, fmt.Errorf("token expired")
+	}
+	if c.Room != room || c.PlayerID != playerID {
+		return c, fmt.Errorf("token is not for this player")
+	}
+	return c, nil
+}
+
+// authorize checks the request carries a valid token for playerID in room and
+// returns its session, writing a 401 and returning false if not.
+// the token has to be in the Authorization header, only /ws takes it from the url
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, room int, playerID string) (string, bool) {
+	return s.authorizeToken(w, bearer(r), room, playerID)
+}
+
+func (s *Server) authorizeToken(w http.ResponseWriter, token string, room int, playerID string) (string, bool) {
+	c, err := s.auth.verify(token, room, playerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return "", false
+	}
+	return c.Session, true
+}
+
+// status for an error from the room, session errors are 401, a frozen table
+// is 409 and anything else is fallback
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, errBadSession):
+		return http.StatusUnauthorized
+	case errors.Is(err, errTableFrozen):
+		return http.StatusConflict
+	}
+	return fallback
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// what test accounts are granted
+const testBankroll = 1000 * Chip
+
+// account secrets by bank and player id, so joining again uses the same account
+var testSecrets = map[string]string{}
+
+// account makes id an account with testBankroll in it the first time it is asked for, returns its secret
+func account(t *testing.T, s *Server, id string) string {
+	t.Helper()
+	key := fmt.Sprintf("%p/%s", s.bank, id)
+	if secret, ok := testSecrets[key]; ok {
+		return secret
+	}
+	secret, err := s.bank.create(id)
+	if err != nil {
+		t.Fatalf("account %s: %v", id, err)
+	}
+	if err := s.bank.grant(id, testBankroll); err != nil {
+		t.Fatalf("grant %s: %v", id, err)
+	}
+	testSecrets[key] = secret
+	return secret
+}
+
+// join room 1 over http with the player's account and return the session token
+func joinHTTP(t *testing.T, s *Server, base, id string) string {
+	t.Helper()
+	body := `{"id":"` + id + `","name":"p` + id + `","stack":100}`
+	req, _ := http.NewRequest(http.MethodPost, base+"/join?room=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+account(t, s, id))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("join %s: %v", id, err)
+	}
+	defer resp.Body.Close()
+	var jr JoinResponse
+	if err := json.NewDecoder(resp.Body).Decode(&jr); err != nil || jr.Token == "" {
+		t.Fatalf("join %s: status %d, no token", id, resp.StatusCode)
+	}
+	return jr.Token
+}
+
+// POST with an optional bearer token, returns the status code
+func postHTTP(t *testing.T, url, token, body string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMutatingEndpointsNeedToken(t *testing.T) {
+	s := newServer()
+	s.addRoom(RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	s.addRoom(RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	srv := httptest.NewServer(withCORS(s.routes()))
+	defer srv.Close()
+
+	t1 := joinHTTP(t, s, srv.URL, "1")
+	t2 := joinHTTP(t, s, srv.URL, "2")
+	sit := srv.URL + "/sitInOrOut?room=1&sitIn=true&playerId=1"
+
+	if code := postHTTP(t, sit, "", ""); code != http.StatusUnauthorized {
+		t.Fatalf("no token: %d, want 401", code)
+	}
+	if code := postHTTP(t, sit, t2, ""); code != http.StatusUnauthorized {
+		t.Fatalf("another player's token: %d, want 401", code)
+	}
+	if code := postHTTP(t, sit, t1[:len(t1)-2]+"xx", ""); code != http.StatusUnauthorized {
+		t.Fatalf("tampered token: %d, want 401", code)
+	}
+	if code := postHTTP(t, srv.URL+"/sitInOrOut?room=2&sitIn=true&playerId=1", t1, ""); code != http.StatusUnauthorized {
+		t.Fatalf("token used in the wrong room: %d, want 401", code)
+	}
+	if code := postHTTP(t, srv.URL+"/action?room=1", t2, `{"playerId":"1","action":"fold"}`); code != http.StatusUnauthorized {
+		t.Fatalf("acting for another player: %d, want 401", code)
+	}
+	if code := postHTTP(t, sit+"&token="+t1, "", ""); code != http.StatusUnauthorized {
+		t.Fatalf("token in the url: %d, want 401", code)
+	}
+	if code := postHTTP(t, sit, t1, ""); code != http.StatusOK {
+		t.Fatalf("own token: %d, want 200", code)
+	}
+
+	// after leaving and joining again the old token belongs to a dead session
+	if code := postHTTP(t, srv.URL+"/leave?room=1", t1, `{"id":"1"}`); code != http.StatusOK {
+		t.Fatalf("leave: %d", code)
+	}
+	joinHTTP(t, s, srv.URL, "1")
+	if code := postHTTP(t, srv.URL+"/leave?room=1", t1, `{"id":"1"}`); code != http.StatusUnauthorized {
+		t.Fatalf("old session token: %d, want 401", code)
+	}
+
+	resp, err := http.Get(srv.URL + "/state?room=1&playerId=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("private state without a token: %d, want 401", resp.StatusCode)
+	}
+}
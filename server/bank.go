@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// one movement of chips in or out of a player's bankroll. entries are only ever appended
+type LedgerEntry struct {
+	Seq      int       `json:"seq"`
+	PlayerID string    `json:"playerId"`
+	Kind     string    `json:"kind"`    // "grant", "buy_in" or "cash_out"
+	Amount   Chips     `json:"amount"`  // into the bankroll, negative for buy ins
+	Balance  Chips     `json:"balance"` // after this entry
+	Room     int       `json:"room,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Account is a bankroll's owner. the secret is only shown once, when the account
+// is made, and has to come with every join and bankroll request after that
+type Account struct {
+	PlayerID string    `json:"playerId"`
+	Secret   string    `json:"secret"` // sha256 of it in hex, never the secret itself
+	Created  time.Time `json:"created"`
+}
+
+// no account for that id, or not its secret. both look the same to the caller
+var errBadAccount = errors.New("unknown account or wrong secret")
+
+// Bank holds every player's bankroll, shared by all rooms. a new account has
+// nothing in it, chips only come in through grants (see grantHandler) and cash outs
+type Bank struct {
+	mu       sync.Mutex
+	accounts map[string]Account
+	balances map[string]Chips
+	ledger   []LedgerEntry
+	store    *Store // accounts go to accounts.jsonl and every entry to ledger.jsonl, nil to keep nothing
+}
+
+func newBank() *Bank {
+	return &Bank{accounts: make(map[string]Account), balances: make(map[string]Chips)}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// create opens an empty bankroll for id and returns its secret
+func (b *Bank) create(id string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.accounts[id]; ok {
+		return "", fmt.Errorf("account %s already exists", id)
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(raw)
+	a := Account{PlayerID: id, Secret: hashSecret(secret), Created: time.Now()}
+	if b.store != nil {
+		// an account we can't keep would lose its chips on the next restart
+		if err := b.store.appendJSONL("accounts.jsonl", a); err != nil {
+			return "", fmt.Errorf("can't save account %s: %w", id, err)
+		}
+	}
+	b.accounts[id] = a
+	return secret, nil
+}
+
+// check is nil if secret belongs to the account id
+func (b *Bank) check(id, secret string) error {
+	b.mu.Lock()
+	a, ok := b.accounts[id]
+	b.mu.Unlock()
+	if !ok || secret == "" || !hmac.Equal([]byte(a.Secret), []byte(hashSecret(secret))) {
+		return errBadAccount
+	}
+	return nil
+}
+
+// record appends to the ledger and moves the balance, caller holds mu.
+// the entry goes to disk first: the balances are rebuilt from ledger.jsonl on
+// a restart, so one that can't be saved changes nothing
+func (b *Bank) record(id, kind string, amount Chips, room int) error {
+	e := LedgerEntry{
+		Seq:      len(b.ledger) + 1,
+		PlayerID: id,
+		Kind:     kind,
+		Amount:   amount,
+		Balance:  b.balances[id] + amount,
+		Room:     room,
+		Time:     time.Now(),
+	}
+	if b.store != nil {
+		if err := b.store.appendJSONL("ledger.jsonl", e); err != nil {
+			return fmt.Errorf("can't save ledger entry for %s: %w", id, err)
+		}
+	}
+	b.balances[id] = e.Balance
+	b.ledger = append(b.ledger, e)
+	return nil
+}
+
+// grant puts new chips into an account, the only way chips come into the game
+func (b *Bank) grant(id string, amount Chips) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.accounts[id]; !ok {
+		return fmt.Errorf("no account %s", id)
+	}
+	if amount <= 0 {
+		return fmt.Errorf("grant must be positive")
+	}
+	return b.record(id, "grant", amount, 0)
+}
+
+func (b *Bank) balance(id string) Chips {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.balances[id]
+}
+
+// buyIn takes chips out of the bankroll to sit down with
+func (b *Bank) buyIn(id string, room int, amount Chips) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.accounts[id]; !ok {
+		return fmt.Errorf("no account %s, make one with POST /accounts", id)
+	}
+	if b.balances[id] < amount {
+		return fmt.Errorf("bankroll is %s, not enough to buy in for %s", b.balances[id], amount)
+	}
+	return b.record(id, "buy_in", -amount, room)
+}
+
+// cashOut puts a player's stack back when they leave a room
+func (b *Bank) cashOut(id string, room int, amount Chips) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.record(id, "cash_out", amount, room)
+}
+
+// a copy of a player's ledger entries, oldest first
+func (b *Bank) entries(id string) []LedgerEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := []LedgerEntry{}
+	for _, e := range b.ledger {
+		if e.PlayerID == id {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// the account secret, session token or admin key, only ever taken from
+// "Authorization: Bearer <secret>" so it doesn't end up in urls and logs
+func bearer(r *http.Request) string {
+	secret, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return secret
+}
+
+/*
+make an account, the secret in the response is never shown again
+
+	curl -X POST "http://localhost:8080/accounts" -d '{"id":"1234"}'
+	-> 201 {"playerId":"1234","secret":"..."}
+
+the bankroll starts empty, see /grants
+*/
+func (s *Server) createAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ID == "" {
+		http.Error(w, "bad json (need id)", http.StatusBadRequest)
+		return
+	}
+	if _, err := strconv.Atoi(body.ID); err != nil {
+		http.Error(w, "id must be an int", http.StatusBadRequest)
+		return
+	}
+	secret, err := s.bank.create(body.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(AccountResponse{PlayerID: body.ID, Secret: secret})
+}
+
+type AccountResponse struct {
+	PlayerID string `json:"playerId"`
+	Secret   string `json:"secret"`
+}
+
+/*
+give an account chips, for whoever runs the server. needs POKER_ADMIN_KEY, grants are off without it
+
+	curl -X POST "http://localhost:8080/grants" -H "Authorization: Bearer $POKER_ADMIN_KEY" \
+	  -d '{"playerId":"1234","amount":1000}'
+*/
+func (s *Server) grantHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(w, r) {
+		return
+	}
+	var body struct {
+		PlayerID string `json:"playerId"`
+		Amount   Chips  `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.PlayerID == "" {
+		http.Error(w, "bad json (need playerId, amount)", http.StatusBadRequest)
+		return
+	}
+	if err := s.bank.grant(body.PlayerID, body.Amount); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(BankrollResponse{PlayerID: body.PlayerID, Balance: s.bank.balance(body.PlayerID), Ledger: s.bank.entries(body.PlayerID)})
+}
+
+// isAdmin checks the request has "Authorization: Bearer <POKER_ADMIN_KEY>",
+// writing a 403 when there is no admin key and a 401 when it's wrong
+func (s *Server) isAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminKey == "" {
+		http.Error(w, "admin requests are turned off, start the server with POKER_ADMIN_KEY", http.StatusForbidden)
+		return false
+	}
+	if !hmac.Equal([]byte(bearer(r)), []byte(s.adminKey)) {
+		http.Error(w, "wrong admin key", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+type BankrollResponse struct {
+	PlayerID string        `json:"playerId"`
+	Balance  Chips         `json:"balance"` // not counting chips on a table
+	Ledger   []LedgerEntry `json:"ledger"`
+}
+
+// GET /bankroll?playerId=2 with "Authorization: Bearer <account secret>" -> { playerId, balance, ledger }
+func (s *Server) bankrollHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("playerId")
+	if id == "" {
+		http.Error(w, "missing playerId", http.StatusBadRequest)
+		return
+	}
+	if err := s.bank.check(id, bearer(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	resp := BankrollResponse{PlayerID: id, Balance: s.bank.balance(id), Ledger: s.bank.entries(id)}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
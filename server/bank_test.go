@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBankBuyInAndCashOut(t *testing.T) {
+	b := newBank()
+	if err := b.buyIn("1", 1, 10*Chip); err == nil {
+		t.Fatalf("bought in without an account")
+	}
+	secret, err := b.create("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.create("1"); err == nil {
+		t.Fatalf("made account 1 twice")
+	}
+	if b.check("1", secret) != nil || b.check("1", "guess") == nil || b.check("2", secret) == nil {
+		t.Fatalf("account secret checks")
+	}
+	// nothing in a new account until it is granted some
+	if err := b.buyIn("1", 1, 10*Chip); err == nil {
+		t.Fatalf("bought in with an empty bankroll")
+	}
+	if err := b.grant("1", 100*Chip); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.buyIn("1", 1, 150*Chip); err == nil {
+		t.Fatalf("bought in for more than the bankroll")
+	}
+	if err := b.buyIn("1", 1, 60*Chip); err != nil {
+		t.Fatalf("buy in: %v", err)
+	}
+	if err := b.cashOut("1", 1, 75*Chip); err != nil {
+		t.Fatalf("cash out: %v", err)
+	}
+	if bal := b.balance("1"); bal != 115*Chip {
+		t.Fatalf("balance = %s, want 115", bal)
+	}
+	got := b.entries("1")
+	want := []struct {
+		kind            string
+		amount, balance Chips
+	}{{"grant", 100 * Chip, 100 * Chip}, {"buy_in", -60 * Chip, 40 * Chip}, {"cash_out", 75 * Chip, 115 * Chip}}
+	if len(got) != len(want) {
+		t.Fatalf("ledger = %+v", got)
+	}
+	for i, w := range want {
+		if got[i].Kind != w.kind || got[i].Amount != w.amount || got[i].Balance != w.balance || got[i].Seq != i+1 {
+			t.Fatalf("entry %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestUnsavedLedgerEntryMovesNoChips(t *testing.T) {
+	dir := t.TempDir()
+	st, _ := openStore(dir)
+	b, err := loadBank(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.create("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.grant("1", 100*Chip); err != nil {
+		t.Fatal(err)
+	}
+
+	// with the directory gone nothing can be appended to ledger.jsonl
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	r := newRoom(1, RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	r.bank = b
+	if err := r.join(newPlayer("1", "p1", 50*Chip)); err == nil || FindPlayerIndexInRoom(r, "1") >= 0 {
+		t.Fatalf("joined without the buy in being saved")
+	}
+	if err := b.grant("1", 10*Chip); err == nil {
+		t.Fatalf("granted without saving it")
+	}
+	if err := b.cashOut("1", 1, 10*Chip); err == nil {
+		t.Fatalf("cashed out without saving it")
+	}
+	if bal, n := b.balance("1"), len(b.entries("1")); bal != 100*Chip || n != 1 {
+		t.Fatalf("balance %s with %d entries, want 100 and 1", bal, n)
+	}
+}
+
+func TestRoomMovesChipsThroughBank(t *testing.T) {
+	r := newRoom(1, RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	r.bank = newBank()
+	for _, id := range []string{"1", "2", "3", "4"} {
+		if _, err := r.bank.create(id); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.bank.grant(id, 500*Chip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := r.join(newPlayer(id, "p"+id, 100*Chip)); err != nil {
+			t.Fatalf("join %s: %v", id, err)
+		}
+		if err := r.sit(id, true); err != nil {
+			t.Fatalf("sit in %s: %v", id, err)
+		}
+	}
+	if err := r.join(newPlayer("4", "p4", 600*Chip)); err == nil || FindPlayerIndexInRoom(r, "4") >= 0 {
+		t.Fatalf("joined with more than their bankroll")
+	}
+	if bal := r.bank.balance("1"); bal != 400*Chip {
+		t.Fatalf("balance after buy in = %s, want 400", bal)
+	}
+
+	// seat 1 folds, small blind leaves mid hand, big blind wins 1
+	r.startNextHandIfReady()
+	if err := r.leave("2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.action(Action{PlayerID: "1", Action: "fold"}); err != nil {
+		t.Fatal(err)
+	}
+	if bal := r.bank.balance("2"); bal != 499*Chip {
+		t.Fatalf("player 2 cashed out to %s, want 499", bal)
+	}
+	for _, id := range []string{"1", "3"} {
+		if err := r.leave(id); err != nil && r.currentHand == nil {
+			t.Fatal(err)
+		}
+	}
+	// whatever happened at the table, no chips were made or lost
+	total := Chips(0)
+	for _, id := range []string{"1", "2", "3"} {
+		total += r.bank.balance(id)
+	}
+	for _, p := range r.players {
+		total += p.Stack
+	}
+	if total != 1500*Chip {
+		t.Fatalf("bankrolls and stacks add up to %s, want 1500", total)
+	}
+}
+
+func TestBankrollEndpoint(t *testing.T) {
+	s := newServer()
+	s.adminKey = "admin"
+	s.addRoom(RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	srv := httptest.NewServer(withCORS(s.routes()))
+	defer srv.Close()
+
+	do := func(method, path, secret, body string) (int, []byte) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+
+	code, b := do(http.MethodPost, "/accounts", "", `{"id":"5"}`)
+	var acct AccountResponse
+	if code != http.StatusCreated || json.Unmarshal(b, &acct) != nil || acct.Secret == "" {
+		t.Fatalf("create account: %d %s", code, b)
+	}
+	if code, _ := do(http.MethodPost, "/accounts", "", `{"id":"5"}`); code != http.StatusConflict {
+		t.Fatalf("account 5 again: %d, want 409", code)
+	}
+	other := account(t, s, "6")
+
+	// chips only come from someone with the admin key
+	grant := `{"playerId":"5","amount":200}`
+	if code, _ := do(http.MethodPost, "/grants", acct.Secret, grant); code != http.StatusUnauthorized {
+		t.Fatalf("grant with a player's secret: %d, want 401", code)
+	}
+	s.adminKey = ""
+	if code, _ := do(http.MethodPost, "/grants", "", grant); code != http.StatusForbidden {
+		t.Fatalf("grant with grants off: %d, want 403", code)
+	}
+	s.adminKey = "admin"
+	if code, b := do(http.MethodPost, "/grants", "admin", grant); code != http.StatusOK {
+		t.Fatalf("grant: %d %s", code, b)
+	}
+
+	// only the account's secret spends it
+	join := `{"id":"5","name":"p5","stack":100}`
+	for _, secret := range []string{"", other} {
+		if code, _ := do(http.MethodPost, "/join?room=1", secret, join); code != http.StatusUnauthorized {
+			t.Fatalf("join as 5 with secret %q: %d, want 401", secret, code)
+		}
+	}
+	if code, _ := do(http.MethodPost, "/join?room=1", "", `{"id":"9","name":"p9","stack":100}`); code != http.StatusUnauthorized {
+		t.Fatalf("join without an account: %d, want 401", code)
+	}
+	code, b = do(http.MethodPost, "/join?room=1", acct.Secret, join)
+	var jr JoinResponse
+	if code != http.StatusOK || json.Unmarshal(b, &jr) != nil {
+		t.Fatalf("join: %d %s", code, b)
+	}
+	if code := postHTTP(t, srv.URL+"/leave?room=1", jr.Token, `{"id":"5"}`); code != http.StatusOK {
+		t.Fatalf("leave: %d", code)
+	}
+
+	for _, secret := range []string{"", other} {
+		if code, _ := do(http.MethodGet, "/bankroll?playerId=5", secret, ""); code != http.StatusUnauthorized {
+			t.Fatalf("someone else's bankroll: %d, want 401", code)
+		}
+	}
+	code, b = do(http.MethodGet, "/bankroll?playerId=5", acct.Secret, "")
+	var br BankrollResponse
+	if code != http.StatusOK || json.Unmarshal(b, &br) != nil {
+		t.Fatalf("bankroll: %d %s", code, b)
+	}
+	if br.Balance != 200*Chip || len(br.Ledger) != 3 || br.Ledger[0].Kind != "grant" || br.Ledger[1].Kind != "buy_in" || br.Ledger[2].Amount != 100*Chip {
+		t.Fatalf("bankroll = %+v", br)
+	}
+}
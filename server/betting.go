@@ -0,0 +1,188 @@
+package main
+
+import "fmt"
+
+// chips the player still has to put in to match the current bet
+func amountToCall(H *Hand, p Player) Chips {
+	return max(H.currentBet-p.streetBet, 0)
+}
+
+// raiseBounds returns the smallest and largest total a player can raise to this street.
+// the minimum is the current bet plus the last full raise, the maximum is everything
+// the player has (or the pot limit, if that is less). fixed-limit only has one
+// amount, one bet more than the current one. if min > max the player can only go
+// all in for less
+func raiseBounds(H *Hand, p Player) (Chips, Chips) {
+	maxTo := p.streetBet + p.Stack
+	switch H.game.Betting {
+	case PotLimit:
+		maxTo = min(maxTo, potLimit(H, p))
+	case FixedLimit:
+		to := H.currentBet + betSize(H)
+		return to, min(maxTo, to)
+	}
+	return H.currentBet + H.lastRaise, maxTo
+}
+
+// the fixed-limit bet: the big blind pre-flop and on the flop, twice that on the turn and river
+func betSize(H *Hand) Chips {
+	if H.currentState == StateTurn || H.currentState == StateRiver {
+		return 2 * H.minBet
+	}
+	return H.minBet
+}
+
+// fixed-limit streets stop at a bet and three raises, unless only two players are left
+func betsCapped(H *Hand) bool {
+	return H.game.Betting == FixedLimit && H.bets >= limitBetCap && playersInHand(H) > 2
+}
+
+// potLimit is the biggest pot-limit raise: call, then raise by the whole pot
+// including that call. h.pot already has every bet made this street in it
+func potLimit(H *Hand, p Player) Chips {
+	return H.currentBet + H.pot + amountToCall(H, p)
+}
+
+// someone other than i is still able to call a raise
+func othersCanCall(H *Hand, i int) bool {
+	for j, p := range H.Players {
+		if j != i && !p.folded && p.Stack > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// computeAvailableActions works out what the player at index i is allowed to do right now
+func computeAvailableActions(H *Hand, i int) []string {
+	p := H.Players[i]
+	toCall := amountToCall(H, p)
+
+	actions := []string{"fold"}
+	if toCall == 0 {
+		actions = append(actions, "check")
+	} else {
+		actions = append(actions, "call")
+	}
+
+	// a raise is only allowed if the player has chips beyond the call, someone can
+	// still call it, and the betting was reopened for them (no incomplete all in raise)
+	canRaise := p.Stack > toCall && !p.acted && othersCanCall(H, i) && !betsCapped(H)
+	if canRaise {
+		actions = append(actions, "raise")
+	}
+	// under pot-limit and fixed-limit the player can only shove if their stack fits under the limit
+	_, maxTo := raiseBounds(H, p)
+	if (canRaise && p.streetBet+p.Stack <= maxTo) || (toCall > 0 && p.Stack <= toCall) {
+		actions = append(actions, "allin")
+	}
+	return actions
+}
+
+// move chips from the player's stack into the pot
+func commitChips(H *Hand, i int, amount Chips) {
+	amount = min(amount, H.Players[i].Stack)
+	H.Players[i].Stack -= amount
+	H.Players[i].streetBet += amount
+	H.Players[i].totalBet += amount
+	H.pot += amount
+}
+
+// raiseTo makes the player's bet this street equal to total, reopening the betting
+// for everyone else if it was a full raise
+func raiseTo(H *Hand, i int, total Chips) {
+	commitChips(H, i, total-H.Players[i].streetBet)
+
+	raiseSize := total - H.currentBet
+	fullRaise := raiseSize >= H.lastRaise
+	if fullRaise {
+		H.lastRaise = raiseSize
+		H.bets++
+	}
+	H.currentBet = total
+
+	for j := range H.Players {
+		p := &H.Players[j]
+		if j == i || p.folded || p.Stack == 0 {
+			continue
+		}
+		// everyone has to respond to the new bet, but only a full raise lets them raise again
+		p.canAct = true
+		if fullRaise {
+			p.acted = false
+		}
+	}
+}
+
+// resetStreet clears the per street betting state before a new street is dealt
+func resetStreet(H *Hand) {
+	H.currentBet = 0
+	H.lastRaise = H.minBet
+	H.bets = 0
+	if H.game.Betting == FixedLimit {
+		H.lastRaise = betSize(H)
+	}
+	for i := range H.Players {
+		H.Players[i].streetBet = 0
+		H.Players[i].acted = false
+		H.Players[i].canAct = !H.Players[i].folded && H.Players[i].Stack > 0
+	}
+}
+
+// take action from channel and do it (mutates H via pointer).
+// returns an error and leaves the hand untouched if the action is not legal
+func handleAction(H *Hand, action Action) error {
+	i := H.actionPlayerIndex
+	if i < 0 || i >= len(H.Players) || H.Players[i].ID != action.PlayerID {
+		return fmt.Errorf("not your turn")
+	}
+	// if action cannot be done, return
+	if !contains(H.avaliableActions, action.Action) {
+		return fmt.Errorf("%s is not allowed, can do: %v", action.Action, H.avaliableActions)
+	}
+	p := &H.Players[i]
+
+	switch action.Action {
+	case "raise":
+		minTo, maxTo := raiseBounds(H, *p)
+		allIn := p.streetBet + p.Stack
+		if action.Amount > maxTo && maxTo < allIn {
+			switch H.game.Betting {
+			case FixedLimit:
+				return fmt.Errorf("fixed-limit raises are to exactly %s", maxTo)
+			default:
+				return fmt.Errorf("raise to %s is over the pot limit, max is %s", action.Amount, maxTo)
+			}
+		}
+		if action.Amount > maxTo {
+			return fmt.Errorf("raise to %s exceeds stack, max is %s", action.Amount, maxTo)
+		}
+		// less than a min raise is only ok when it puts the player all in
+		if action.Amount < minTo && action.Amount != allIn {
+			return fmt.Errorf("raise must be to at least %s", min(minTo, maxTo))
+		}
+		raiseTo(H, i, action.Amount)
+
+	case "allin":
+		total := p.streetBet + p.Stack
+		if total > H.currentBet {
+			raiseTo(H, i, total)
+		} else {
+			commitChips(H, i, p.Stack)
+		}
+
+	case "call":
+		// short stacks call all in for less
+		commitChips(H, i, amountToCall(H, *p))
+
+	case "check":
+
+	case "fold":
+		// folded players stay in the hand so their chips still count towards the pots
+		p.folded = true
+	}
+
+	p.acted = true
+	p.canAct = false
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// a hand on the flop with everyone to act and no bets yet, stacks in whole chips
+func bettingHand(stacks ...float64) *Hand {
+	players := make([]Player, len(stacks))
+	for i, s := range stacks {
+		players[i] = Player{ID: string(rune('A' + i)), Stack: toChips(s)}
+	}
+	h := &Hand{Players: players, minBet: 10 * Chip}
+	resetStreet(h)
+	return h
+}
+
+// act as the player at index i, failing the test if the action is rejected
+func mustAct(t *testing.T, h *Hand, i int, action string, amount float64) {
+	t.Helper()
+	h.actionPlayerIndex = i
+	h.avaliableActions = computeAvailableActions(h, i)
+	if err := handleAction(h, Action{PlayerID: h.Players[i].ID, Action: action, Amount: toChips(amount)}); err != nil {
+		t.Fatalf("%s %s %.0f: %v", h.Players[i].ID, action, amount, err)
+	}
+}
+
+func TestCheckAndCallAmounts(t *testing.T) {
+	h := bettingHand(100, 100, 30)
+
+	if got := computeAvailableActions(h, 0); !contains(got, "check") || contains(got, "call") {
+		t.Fatalf("unopened pot actions = %v", got)
+	}
+	mustAct(t, h, 0, "raise", 40)
+	mustAct(t, h, 1, "call", 0)
+	// C only has 30, calling puts them all in for less
+	mustAct(t, h, 2, "call", 0)
+
+	if h.Players[1].Stack != 60*Chip || h.Players[2].Stack != 0 || h.pot != 110*Chip {
+		t.Fatalf("stacks %s/%s pot %s, want 60/0 pot 110", h.Players[1].Stack, h.Players[2].Stack, h.pot)
+	}
+	if nextEligible(h, 0) != -1 {
+		t.Fatalf("betting round should be closed")
+	}
+}
+
+func TestRaiseValidation(t *testing.T) {
+	h := bettingHand(100, 100)
+	mustAct(t, h, 0, "raise", 20)
+
+	h.actionPlayerIndex = 1
+	h.avaliableActions = computeAvailableActions(h, 1)
+	// min raise is to 40 (20 bet + 20 raise)
+	if err := handleAction(h, Action{PlayerID: "B", Action: "raise", Amount: 30 * Chip}); err == nil {
+		t.Fatalf("raise below the minimum should be rejected")
+	}
+	if err := handleAction(h, Action{PlayerID: "B", Action: "raise", Amount: 150 * Chip}); err == nil {
+		t.Fatalf("raise above the stack should be rejected")
+	}
+	if err := handleAction(h, Action{PlayerID: "A", Action: "fold"}); err == nil {
+		t.Fatalf("acting out of turn should be rejected")
+	}
+	if h.Players[1].Stack != 100*Chip {
+		t.Fatalf("rejected actions should not move chips")
+	}
+	mustAct(t, h, 1, "raise", 40)
+	if h.lastRaise != 20*Chip || h.currentBet != 40*Chip {
+		t.Fatalf("lastRaise %s currentBet %s, want 20 / 40", h.lastRaise, h.currentBet)
+	}
+}
+
+func TestIncompleteAllInDoesNotReopenBetting(t *testing.T) {
+	h := bettingHand(200, 200, 55)
+	mustAct(t, h, 0, "raise", 40)
+	mustAct(t, h, 1, "call", 0)
+	// C shoves 55, only 15 more than the bet and less than a full raise of 40
+	mustAct(t, h, 2, "allin", 0)
+
+	got := computeAvailableActions(h, 0)
+	if !contains(got, "call") || contains(got, "raise") {
+		t.Fatalf("after an incomplete raise A can do %v, want call but no raise", got)
+	}
+	if !h.Players[0].canAct || !h.Players[1].canAct {
+		t.Fatalf("A and B still have to respond to the all in")
+	}
+}
+
+// a fixed-limit 1/2 hold'em hand dealt with the button on the first player
+func limitHand(ids ...string) *Hand {
+	players := []Player{}
+	for _, id := range ids {
+		players = append(players, Player{ID: id, Stack: 100 * Chip})
+	}
+	pos := Positions{Dealer: 0, SmallBlind: 1, BigBlind: 2}
+	if len(ids) == 2 {
+		pos = Positions{Dealer: 0, SmallBlind: 0, BigBlind: 1}
+	}
+	limit, _ := variantFor("holdem", FixedLimit)
+	h := newHand(players, pos, Chip, 2*Chip, 0, Seed{}, limit)
+	h.start()
+	return h
+}
+
+func TestFixedLimitBetsAndCap(t *testing.T) {
+	h := limitHand("A", "B", "C")
+
+	// pre-flop raises are one small bet (the big blind) at a time
+	if minTo, maxTo := raiseBounds(h, h.Players[0]); minTo != 4*Chip || maxTo != 4*Chip {
+		t.Fatalf("raise to %s-%s, want exactly 4", minTo, maxTo)
+	}
+	err := h.act(Action{PlayerID: "A", Action: "raise", Amount: 10 * Chip})
+	if err == nil || err.Error() != "fixed-limit raises are to exactly 4" {
+		t.Fatalf("raise to 10: %v, want fixed-limit raises are to exactly 4", err)
+	}
+	// the big blind is the first bet, so three raises cap it at 8
+	for _, a := range []Action{{"A", "raise", 4 * Chip}, {"B", "raise", 6 * Chip}, {"C", "raise", 8 * Chip}} {
+		if err := h.act(a); err != nil {
+			t.Fatalf("%s to %s: %v", a.PlayerID, a.Amount, err)
+		}
+	}
+	if contains(h.avaliableActions, "raise") || !handView(h, time.Time{}).Capped {
+		t.Fatalf("after the cap A can %v", h.avaliableActions)
+	}
+	for _, a := range []Action{{"A", "call", 0}, {"B", "call", 0}} {
+		if err := h.act(a); err != nil {
+			t.Fatalf("%s %s: %v", a.PlayerID, a.Action, err)
+		}
+	}
+
+	// flop bets are still 2, the turn's are 4
+	if h.currentState != StateFlop || handView(h, time.Time{}).BetSize != 2*Chip {
+		t.Fatalf("%s bet size %s, want the flop and 2", h.currentState, betSize(h))
+	}
+	for _, id := range []string{"B", "C", "A"} {
+		if err := h.act(Action{PlayerID: id, Action: "check"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := privateView(h, "B"); h.currentState != StateTurn || v.MinRaise != 4*Chip || v.MaxRaise != 4*Chip {
+		t.Fatalf("%s: B can bet %s-%s, want 4 on the turn", h.currentState, v.MinRaise, v.MaxRaise)
+	}
+}
+
+func TestFixedLimitHeadsUpIsUncapped(t *testing.T) {
+	h := limitHand("A", "B")
+	// heads up the button is the small blind and acts first
+	bet := 2 * Chip
+	for i := 0; i < 6; i++ {
+		id := []string{"A", "B"}[i%2]
+		bet += 2 * Chip
+		if err := h.act(Action{PlayerID: id, Action: "raise", Amount: bet}); err != nil {
+			t.Fatalf("raise %d, %s to %s: %v", i+1, id, bet, err)
+		}
+	}
+}
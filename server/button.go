@@ -0,0 +1,143 @@
+package main
+
+import "sort"
+
+// where the button and blinds are for a hand, as indexes into Hand.Players.
+// the button can be on an empty seat (dead button), in that case Dealer is the
+// closest player before it so action still starts at the right place
+type Positions struct {
+	Dealer     int
+	SmallBlind int // -1 when the small blind is dead
+	BigBlind   int
+	ButtonSeat int // the seat the button is on, empty for a dead button. 0 means the dealer's seat
+}
+
+// first seat after seat (wrapping around) out of seats, which are sorted low -> high
+func nextSeat(seats []int, seat int) int {
+	for _, s := range seats {
+		if s > seat {
+			return s
+		}
+	}
+	return seats[0]
+}
+
+// index of the player sitting in seat, -1 if nobody is
+func seatIndex(players []Player, seat int) int {
+	for i, p := range players {
+		if p.Seat == seat {
+			return i
+		}
+	}
+	return -1
+}
+
+// index of the player in seat, or the closest one before it if the seat is empty
+func indexAtOrBefore(players []Player, seat int) int {
+	idx := len(players) - 1
+	for i, p := range players {
+		if p.Seat <= seat {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// lowest seat nobody is sitting in, -1 if the table is full
+func (r *Room) freeSeat() int {
+	for s := 1; s <= r.cfg.Seats; s++ {
+		if seatIndex(r.players, s) < 0 {
+			return s
+		}
+	}
+	return -1
+}
+
+// keep the roster in seat order, action and the button both go by seat
+func (r *Room) sortBySeat() {
+	sort.Slice(r.players, func(i, j int) bool {
+		return r.players[i].Seat < r.players[j].Seat
+	})
+}
+
+// dealPlayers picks who is dealt into the next hand and where the button and blinds go.
+//
+// it uses the dead button rule: the big blind always moves forward to the next player,
+// the small blind goes to the seat that had the big blind last hand (dead if that player
+// is gone or sitting out) and the button goes to last hand's small blind seat, even if it
+// is empty now. that way nobody skips the big blind and nobody pays it twice.
+// players who join or come back from sitting out wait until the big blind reaches them.
+func (r *Room) dealPlayers() ([]Player, Positions, bool) {
+	ready := []Player{}
+	active := []Player{}
+	for _, p := range r.players {
+		if p.SittingOut || p.Stack <= 0 {
+			continue
+		}
+		ready = append(ready, p)
+		if !p.waitingForBB {
+			active = append(active, p)
+		}
+	}
+	if len(ready) < 2 {
+		return nil, Positions{}, false
+	}
+
+	// not enough players for a game in progress: start over and deal everyone in
+	if len(active) < 2 {
+		r.bigBlindSeat = -1
+		active = ready
+	}
+
+	readySeats := make([]int, len(ready))
+	for i, p := range ready {
+		readySeats[i] = p.Seat
+	}
+
+	var button, sb, bb int
+	dealt := active
+	if r.bigBlindSeat == -1 {
+		// first hand: button to the first player, blinds to the next ones
+		button = active[0].Seat
+		if len(active) == 2 {
+			sb, bb = active[0].Seat, active[1].Seat
+		} else {
+			sb, bb = active[1].Seat, active[2].Seat
+		}
+	} else {
+		bb = nextSeat(readySeats, r.bigBlindSeat)
+		if seatIndex(active, bb) < 0 {
+			// the big blind reached someone who was waiting for it
+			dealt = append(append([]Player{}, active...), ready[seatIndex(ready, bb)])
+			sort.Slice(dealt, func(i, j int) bool { return dealt[i].Seat < dealt[j].Seat })
+		}
+
+		if len(dealt) == 2 {
+			// heads up the button posts the small blind
+			button = dealt[0].Seat
+			if button == bb {
+				button = dealt[1].Seat
+			}
+			sb = button
+		} else {
+			sb = r.bigBlindSeat
+			button = r.smallBlindSeat
+		}
+	}
+
+	r.buttonSeat, r.smallBlindSeat, r.bigBlindSeat = button, sb, bb
+	for i := range r.players {
+		if seatIndex(dealt, r.players[i].Seat) >= 0 {
+			r.players[i].waitingForBB = false
+			dealt[seatIndex(dealt, r.players[i].Seat)].waitingForBB = false
+		}
+	}
+
+	pos := Positions{
+		Dealer:     indexAtOrBefore(dealt, button),
+		SmallBlind: seatIndex(dealt, sb),
+		BigBlind:   seatIndex(dealt, bb),
+		ButtonSeat: button,
+	}
+	return dealt, pos, true
+}
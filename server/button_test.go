@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+// room with a player sitting in each of the given seats
+func roomWithSeats(seats ...int) *Room {
+	r := newRoom(1, RoomConfig{MinStack: 0, MaxStack: 1000 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 9})
+	for _, s := range seats {
+		p := newPlayer(string(rune('A'+s)), "p", 100*Chip)
+		p.SittingOut = false
+		p.Seat = s
+		r.players = append(r.players, p)
+	}
+	return r
+}
+
+func seatsOf(players []Player) []int {
+	out := []int{}
+	for _, p := range players {
+		out = append(out, p.Seat)
+	}
+	return out
+}
+
+func TestButtonMovesBySeat(t *testing.T) {
+	r := roomWithSeats(1, 2, 3, 4)
+
+	r.dealPlayers()
+	if r.buttonSeat != 1 || r.smallBlindSeat != 2 || r.bigBlindSeat != 3 {
+		t.Fatalf("first hand button/sb/bb = %d/%d/%d", r.buttonSeat, r.smallBlindSeat, r.bigBlindSeat)
+	}
+	r.dealPlayers()
+	if r.buttonSeat != 2 || r.smallBlindSeat != 3 || r.bigBlindSeat != 4 {
+		t.Fatalf("second hand button/sb/bb = %d/%d/%d", r.buttonSeat, r.smallBlindSeat, r.bigBlindSeat)
+	}
+}
+
+func TestDeadSmallBlindWhenBigBlindLeaves(t *testing.T) {
+	r := roomWithSeats(1, 2, 3, 4, 5)
+	r.dealPlayers() // button 1, sb 2, bb 3
+
+	// the big blind leaves, next hand the small blind is dead and nobody skips the big blind
+	r.players = append(r.players[:2], r.players[3:]...)
+	dealt, pos, ok := r.dealPlayers()
+	if !ok {
+		t.Fatalf("hand should start")
+	}
+	if r.buttonSeat != 2 || r.bigBlindSeat != 4 || pos.SmallBlind != -1 {
+		t.Fatalf("button %d bb %d sb index %d, want 2 / 4 / dead", r.buttonSeat, r.bigBlindSeat, pos.SmallBlind)
+	}
+	if dealt[pos.BigBlind].Seat != 4 || dealt[pos.Dealer].Seat != 2 {
+		t.Fatalf("big blind index points at seat %d, dealer at seat %d", dealt[pos.BigBlind].Seat, dealt[pos.Dealer].Seat)
+	}
+}
+
+func TestNewPlayerWaitsForBigBlind(t *testing.T) {
+	r := roomWithSeats(1, 3, 5)
+	r.dealPlayers() // button 1, sb 3, bb 5
+
+	p := newPlayer("X", "x", 100*Chip)
+	p.SittingOut = false
+	p.waitingForBB = true
+	p.Seat = 2
+	r.players = append(r.players, p)
+	r.sortBySeat()
+
+	// big blind moves to seat 1, seat 2 sits the hand out
+	dealt, _, _ := r.dealPlayers()
+	if got := seatsOf(dealt); len(got) != 3 || r.bigBlindSeat != 1 {
+		t.Fatalf("dealt seats %v with bb %d, want [1 3 5] with bb 1", got, r.bigBlindSeat)
+	}
+	// now the big blind reaches seat 2 and they are dealt in
+	dealt, _, _ = r.dealPlayers()
+	if got := seatsOf(dealt); len(got) != 4 || r.bigBlindSeat != 2 {
+		t.Fatalf("dealt seats %v with bb %d, want all 4 with bb 2", got, r.bigBlindSeat)
+	}
+}
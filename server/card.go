@@ -6,8 +6,8 @@ import (
 )
 
 type Card struct {
-	Suit string
-	Rank string
+	Suit string `json:"suit"`
+	Rank string `json:"rank"`
 }
 
 // all hand combos (pairs, sets, etc)
@@ -25,21 +25,52 @@ const (
 	StraightFlush HandType = "straight flush"
 )
 
-type BestHand struct {
-	Type   HandType
-	rank   int
-	kicker int
+// strength of each hand type, higher beats lower
+var handTypeStrength = map[HandType]int{
+	HighCard:      0,
+	Pair:          1,
+	TwoPair:       2,
+	ThreeOfAKind:  3,
+	Straight:      4,
+	Flush:         5,
+	FullHouse:     6,
+	Quads:         7,
+	StraightFlush: 8,
 }
 
-type CardFrequency struct {
-	Rank  string
-	Count int
+// short deck has no 2s to 5s, so flushes are harder to make than full houses and beat them
+var shortDeckStrength = map[HandType]int{
+	HighCard:      0,
+	Pair:          1,
+	TwoPair:       2,
+	ThreeOfAKind:  3,
+	Straight:      4,
+	FullHouse:     5,
+	Flush:         6,
+	Quads:         7,
+	StraightFlush: 8,
 }
 
-type SuitFrequency struct {
-	Suit        string
-	highestRank string
-	Count       int
+// how a deck ranks hands: the lowest rank in it (the ace plays below it
+// in the lowest straight, A-2-3-4-5 or A-6-7-8-9) and the order of the hand types
+type handRules struct {
+	lowRank  int
+	strength map[HandType]int
+}
+
+var (
+	standardRules  = handRules{lowRank: 2, strength: handTypeStrength}
+	shortDeckRules = handRules{lowRank: 6, strength: shortDeckStrength}
+)
+
+// BestHand is the best five card hand a player can make.
+// tiebreak holds the ranks that decide between two hands of the same type,
+// most important first (e.g. full house of 9s over 4s -> [9, 4])
+type BestHand struct {
+	Type     HandType
+	Cards    []Card
+	strength int // of Type, under the rules the hand was made with
+	tiebreak []int
 }
 
 func rankToInt(rank string) int {
@@ -50,137 +81,123 @@ func rankToInt(rank string) int {
 	return v
 }
 
-func getCardFrequencies(h Hand, p Player) []CardFrequency {
-	freqs := make(map[string]int)
-
-	// count board cards
-	for _, c := range h.board {
-		freqs[c.Rank]++
-	}
-	// count player cards
-	for _, c := range p.hand {
-		freqs[c.Rank]++
+// compareHands returns 1 if a beats b, -1 if b beats a and 0 if they tie
+func compareHands(a BestHand, b BestHand) int {
+	if a.strength != b.strength {
+		if a.strength > b.strength {
+			return 1
+		}
+		return -1
 	}
-
-	result := make([]CardFrequency, 0, len(freqs))
-	for r, c := range freqs {
-		result = append(result, CardFrequency{Rank: r, Count: c})
+	for i := 0; i < len(a.tiebreak) && i < len(b.tiebreak); i++ {
+		if a.tiebreak[i] > b.tiebreak[i] {
+			return 1
+		}
+		if a.tiebreak[i] < b.tiebreak[i] {
+			return -1
+		}
 	}
-
-	// sort by numeric rank descending
-	sort.Slice(result, func(i, j int) bool {
-		return rankToInt(result[i].Rank) > rankToInt(result[j].Rank)
-	})
-
-	return result
+	return 0
 }
 
-func getSuitFrequencies(h Hand, p Player) []SuitFrequency {
-	freqs := make(map[string]int)
-	highestRanks := [0,0,0,0]
-	// count board cards
-	for _, c := range h.board {
-		freqs[c.Suit]++
-		if c.Rank 
+// straightHigh returns the highest card of a straight made by exactly these 5 ranks
+// (sorted high -> low, no duplicates), 0 if there is no straight. with the ace low
+// A-2-3-4-5 is a 5 high straight, A-6-7-8-9 a 9 high one in short deck
+func straightHigh(ranks []int, lowRank int) int {
+	if len(ranks) != 5 {
+		return 0
 	}
-	// count player cards
-	for _, c := range p.hand {
-		freqs[c.Suit]++
+	if ranks[0]-ranks[4] == 4 {
+		return ranks[0]
 	}
-
-	result := make([]SuitFrequency, 0, len(freqs))
-	for s, c := range freqs {
-		result = append(result, SuitFrequency{Suit: s, Count: c})
+	if ranks[0] == 14 && ranks[1] == lowRank+3 && ranks[4] == lowRank {
+		return lowRank + 3
 	}
-
-	return result
+	return 0
 }
 
-// isStraight returns true if the hand is a straight and returns the rank of the highest card in the straight
-func isStraight(freqs []CardFrequency) (bool, int) {
-	biggestLength := 0
-	length := 0
-	highestCardInStraight := rankToInt(freqs[0].Rank)
-	for i := 0; i < len(freqs)-1; i++ {
-		// descending rank
-		if rankToInt(freqs[i].Rank) != rankToInt(freqs[i+1].Rank)+1 {
-			if length > biggestLength {
-				biggestLength = length
-			}
-			length = 0
+// evaluate ranks a single five card hand
+func (rules handRules) evaluate(cards []Card) BestHand {
+	counts := make(map[int]int)
+	flush := len(cards) == 5
+	for _, c := range cards {
+		counts[rankToInt(c.Rank)]++
+		if c.Suit != cards[0].Suit {
+			flush = false
 		}
-		length++
 	}
 
-	if biggestLength > 4 {
-		return true, highestCardInStraight
-	} else {
-		//re run with ace as 1
-		if freqs[0].Rank == "14" {
-			//remove ace from front and add 1 to the end
-			freqs = append(freqs[1:], CardFrequency{Rank: "1", Count: freqs[0].Count})
-			return isStraight(freqs)
+	// distinct ranks ordered by how many times they appear, then by rank (high -> low)
+	ranks := make([]int, 0, len(counts))
+	for r := range counts {
+		ranks = append(ranks, r)
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		if counts[ranks[i]] != counts[ranks[j]] {
+			return counts[ranks[i]] > counts[ranks[j]]
 		}
+		return ranks[i] > ranks[j]
+	})
 
+	hand := BestHand{Cards: append([]Card{}, cards...), tiebreak: ranks}
+	high := straightHigh(ranks, rules.lowRank)
+
+	switch {
+	case high > 0 && flush:
+		hand.Type = StraightFlush
+		hand.tiebreak = []int{high}
+	case counts[ranks[0]] == 4:
+		hand.Type = Quads
+	case counts[ranks[0]] == 3 && len(ranks) > 1 && counts[ranks[1]] == 2:
+		hand.Type = FullHouse
+	case flush:
+		hand.Type = Flush
+	case high > 0:
+		hand.Type = Straight
+		hand.tiebreak = []int{high}
+	case counts[ranks[0]] == 3:
+		hand.Type = ThreeOfAKind
+	case counts[ranks[0]] == 2 && len(ranks) > 1 && counts[ranks[1]] == 2:
+		hand.Type = TwoPair
+	case counts[ranks[0]] == 2:
+		hand.Type = Pair
+	default:
+		hand.Type = HighCard
 	}
-
-	return false, 0
+	hand.strength = rules.strength[hand.Type]
+	return hand
 }
 
-// isFlush returns true if the hand is a flush and returns the rank of the highest card
-func isFlush(freqs []SuitFrequency) (bool, int) {
-	for _, f := range freqs {
-		if f.Count == 5 {
-			return true, rankToInt(f.Rank)
-		}
+// best returns the best five card hand that can be made from cards (5 to 7 cards)
+func (rules handRules) best(cards []Card) BestHand {
+	if len(cards) <= 5 {
+		return rules.evaluate(cards)
 	}
-	return false, 0
-}
-
-func getPlayerBestHand(h Hand, p Player) BestHand {
 
-	BestHand := BestHand{}
-	freqs := getCardFrequencies(h, p) // sorted high → low
-
-	// QUADS: first rank with count == 4
-	for _, rc := range freqs {
-		if rc.Count == 4 {
-
-			BestHand.Type = Quads
-			BestHand.rank = rankToInt(rc.Rank)
-			// kicker = highest card other than quads
-			for _, kicker := range freqs {
-				if kicker.Rank != rc.Rank {
-					BestHand.kicker = rankToInt(kicker.Rank)
-					break
-				}
+	var best BestHand
+	found := false
+	combo := make([]Card, 5)
+	// walk every 5 card combination
+	var pick func(start int, depth int)
+	pick = func(start int, depth int) {
+		if depth == 5 {
+			h := rules.evaluate(combo)
+			if !found || compareHands(h, best) > 0 {
+				best = h
+				found = true
 			}
-			return BestHand
+			return
 		}
-	}
-
-	//check full house(any card has 3 frequencies and any other has 2 frequencies)
-	for _, rc := range freqs {
-		if rc.Count == 3 {
-			for _, rc2 := range freqs {
-				if rc2.Count == 2 {
-					BestHand.Type = FullHouse
-					BestHand.rank = rankToInt(rc.Rank)
-					BestHand.kicker = rankToInt(rc2.Rank)
-					return BestHand
-				}
-			}
+		for i := start; i <= len(cards)-(5-depth); i++ {
+			combo[depth] = cards[i]
+			pick(i+1, depth+1)
 		}
 	}
+	pick(0, 0)
+	return best
+}
 
-	// check flush(any suit has 5 frequencies)
-
-	//check straight
-	straight, highestCardInStraight := isStraight(freqs)
-	if straight {
-		BestHand.Type = Straight
-		BestHand.rank = highestCardInStraight
-		return BestHand
-	}
-	return BestHand
+// best hand from the player's hole cards and the board, by the rules of the hand's game
+func getPlayerBestHand(h *Hand, p Player) BestHand {
+	return h.game.bestHand(p.hand, h.board)
 }
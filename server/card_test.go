@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// parse "14S 13S 12S" into cards
+func cards(s string) []Card {
+	out := []Card{}
+	for _, f := range strings.Fields(s) {
+		out = append(out, Card{Rank: f[:len(f)-1], Suit: f[len(f)-1:]})
+	}
+	return out
+}
+
+func TestBestHandTypes(t *testing.T) {
+	tests := []struct {
+		cards string
+		want  HandType
+	}{
+		{"14S 13S 12S 11S 10S 2D 3C", StraightFlush},
+		{"5H 4H 3H 2H 14H 14D 14C", StraightFlush},
+		{"9S 9H 9D 9C 2S 3D 4C", Quads},
+		{"9S 9H 9D 4C 4S 3D 2C", FullHouse},
+		{"9S 9H 9D 4C 4S 4D 2C", FullHouse},
+		{"2S 7S 9S 11S 13S 13D 13C", Flush},
+		{"14D 2S 3H 4C 5D 9S 11H", Straight},
+		{"10D 11S 12H 13C 14D 2S 2H", Straight},
+		{"7S 7H 7D 2C 4S 9D 11C", ThreeOfAKind},
+		{"7S 7H 4D 4C 2S 2D 11C", TwoPair},
+		{"7S 7H 4D 3C 2S 9D 11C", Pair},
+		{"14S 12H 9D 7C 5S 3D 2C", HighCard},
+	}
+
+	for _, tt := range tests {
+		got := standardRules.best(cards(tt.cards))
+		if got.Type != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.cards, got.Type, tt.want)
+		}
+		if len(got.Cards) != 5 {
+			t.Errorf("%s: best hand has %d cards", tt.cards, len(got.Cards))
+		}
+	}
+}
+
+func TestCompareHands(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		// type beats type
+		{"9S 9H 4D 4C 2S", "14S 14H 13D 12C 11S", 1},
+		// wheel loses to a six high straight
+		{"14D 2S 3H 4C 5D", "2S 3H 4C 5D 6H", -1},
+		// full house compares trips before the pair
+		{"9S 9H 9D 2C 2S", "8S 8H 8D 14C 14S", 1},
+		// two pair falls through to the kicker
+		{"13S 13H 4D 4C 9S", "13D 13C 4S 4H 8S", 1},
+		// pair kickers are compared all the way down
+		{"7S 7H 14D 10C 3S", "7D 7C 14S 10H 2S", 1},
+		// flush compares every card
+		{"14S 10S 8S 6S 3S", "14H 10H 8H 6H 3H", 0},
+		{"14S 10S 8S 6S 2S", "14H 10H 8H 6H 3H", -1},
+	}
+
+	for _, tt := range tests {
+		got := compareHands(standardRules.best(cards(tt.a)), standardRules.best(cards(tt.b)))
+		if got != tt.want {
+			t.Errorf("compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGetPlayerBestHandUsesBoard(t *testing.T) {
+	h := &Hand{board: cards("14S 14H 14D 13C 2S")}
+	a := Player{hand: cards("13S 3D")}
+	b := Player{hand: cards("12S 12H")}
+
+	ha, hb := getPlayerBestHand(h, a), getPlayerBestHand(h, b)
+	if ha.Type != FullHouse || hb.Type != FullHouse {
+		t.Fatalf("got %s and %s, want two full houses", ha.Type, hb.Type)
+	}
+	if compareHands(ha, hb) != 1 {
+		t.Fatalf("aces full of kings should beat aces full of queens")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Chips is an amount of money in hundredths of a chip. everything the engine,
+// the bankrolls and the saved files do with money is integer arithmetic on this,
+// so splitting a pot can never lose or make up a fraction of a chip.
+// on the wire it is still a plain number of chips: 100, 0.5
+type Chips int64
+
+// one whole chip
+const Chip Chips = 100
+
+// toChips converts a number of chips, rounding to the nearest hundredth
+func toChips(v float64) Chips {
+	return Chips(math.Round(v * float64(Chip)))
+}
+
+// chips without trailing zeros, 0.5 and 2 rather than 0.50 and 2.00
+func (c Chips) String() string {
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	s := fmt.Sprintf("%s%d", sign, c/Chip)
+	if frac := c % Chip; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%02d", frac), "0")
+	}
+	return s
+}
+
+func (c Chips) MarshalJSON() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// amounts finer than a hundredth of a chip are refused rather than rounded
+func (c *Chips) UnmarshalJSON(b []byte) error {
+	var v float64
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	n := toChips(v)
+	if math.Abs(v*float64(Chip)-float64(n)) > 1e-6 {
+		return fmt.Errorf("%s chips is finer than a hundredth of a chip", b)
+	}
+	*c = n
+	return nil
+}
+
+// tableChips is every chip in the hand, in front of the players or in the pot
+func tableChips(h *Hand) Chips {
+	total := h.pot
+	for _, p := range h.Players {
+		total += p.Stack
+	}
+	return total
+}
+
+// checkChips makes sure the hand still has exactly the chips it started with
+func checkChips(h *Hand) error {
+	if got := tableChips(h); got != h.chips {
+		return fmt.Errorf("hand %s has %s chips on the table, started with %s", h.id, got, h.chips)
+	}
+	return nil
+}
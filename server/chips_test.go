@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestChipsJSON(t *testing.T) {
+	var cfg RoomConfig
+	if err := json.Unmarshal([]byte(`{"minStack":30,"smallBlind":0.5,"ante":0.05}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MinStack != 30*Chip || cfg.SmallBlind != Chip/2 || cfg.Ante != 5 {
+		t.Fatalf("decoded %d / %d / %d", cfg.MinStack, cfg.SmallBlind, cfg.Ante)
+	}
+	b, _ := json.Marshal(Event{Amount: -1050, Shares: []Chips{Chip, 2 * Chip}})
+	if string(b) != `{"type":"","room":0,"amount":-10.5,"shares":[1,2]}` {
+		t.Fatalf("encoded %s", b)
+	}
+	if err := json.Unmarshal([]byte(`{"stack":10.005}`), &Player{}); err == nil {
+		t.Fatalf("accepted a thousandth of a chip")
+	}
+}
+
+func TestSplitPotKeepsEveryChip(t *testing.T) {
+	// 10.01 between three: 3 each, the odd whole chip and the odd hundredth go left of the button
+	h := &Hand{Players: []Player{{ID: "A"}, {ID: "B"}, {ID: "C"}}, dealerIndex: 2}
+	shares := splitPot(h, 1001, []int{2, 1, 0})
+	if shares[0] != 401 || shares[1] != 300 || shares[2] != 300 || h.Players[0].Stack != 401 {
+		t.Fatalf("shares %v", shares)
+	}
+}
+
+func TestCheckChips(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3")
+	r.startNextHandIfReady()
+	h := r.currentHand
+	if err := r.action(Action{PlayerID: "1", Action: "raise", Amount: 6 * Chip}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkChips(h); err != nil {
+		t.Fatalf("after a raise: %v", err)
+	}
+	h.Players[0].Stack += Chip / 100
+	if err := checkChips(h); err == nil {
+		t.Fatalf("a hundredth of a chip appeared and nobody noticed")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// the action clock. a player on the clock first gets the room's action timeout,
+// when that runs out their time bank starts draining, and when that is gone too
+// they are checked or folded. the time bank is topped up a little after each hand
+
+const (
+	defaultTimeBank       = 60 // seconds
+	defaultTimeBankRefill = 5  // seconds back after each hand dealt in
+)
+
+func (c RoomConfig) timeBank() float64 {
+	if c.TimeBank <= 0 {
+		return defaultTimeBank
+	}
+	return c.TimeBank
+}
+
+func (c RoomConfig) timeBankRefill() float64 {
+	if c.TimeBankRefill <= 0 {
+		return defaultTimeBankRefill
+	}
+	return c.TimeBankRefill
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// startClock puts whoever acts next in the current hand on the base clock
+func (r *Room) startClock() {
+	h := r.currentHand
+	cur := h.Players[h.actionPlayerIndex]
+	timeout := r.cfg.actionTimeout()
+	r.clock = time.NewTimer(timeout)
+	r.clockDeadline = time.Now().Add(timeout)
+	r.clockPlayer = cur.ID
+	r.clockTurn = h.turns
+	r.inTimeBank = false
+	r.broadcast(Event{Type: EventTimer, PlayerID: cur.ID, Seat: cur.Seat, Seconds: timeout.Seconds()})
+}
+
+// stopClock stops the clock, charging whatever time bank was used to the player it ran for
+func (r *Room) stopClock() {
+	if r.clock == nil {
+		return
+	}
+	r.clock.Stop()
+	r.clock = nil
+	if r.inTimeBank {
+		if i := FindPlayerIndexInRoom(r, r.clockPlayer); i >= 0 {
+			p := &r.players[i]
+			p.TimeBank = max(0, p.TimeBank-time.Since(r.timeBankStarted).Seconds())
+		}
+	}
+	r.inTimeBank = false
+}
+
+// clockExpired runs when the clock goes off: the base clock rolls over into
+// the time bank if there is any left, otherwise the player times out
+func (r *Room) clockExpired() {
+	r.clock = nil
+	i := FindPlayerIndexInRoom(r, r.clockPlayer)
+	if !r.inTimeBank && i >= 0 && r.players[i].TimeBank > 0 {
+		p := r.players[i]
+		bank := seconds(p.TimeBank)
+		r.inTimeBank = true
+		r.timeBankStarted = time.Now()
+		r.clock = time.NewTimer(bank)
+		r.clockDeadline = r.timeBankStarted.Add(bank)
+		r.broadcast(Event{Type: EventTimeBank, PlayerID: p.ID, Seat: p.Seat, Seconds: p.TimeBank})
+		return
+	}
+	if r.inTimeBank && i >= 0 {
+		r.players[i].TimeBank = 0
+	}
+	r.inTimeBank = false
+	fmt.Printf("player %s timed out in room %d\n", r.clockPlayer, r.id)
+	r.timedOut(r.clockPlayer)
+	r.currentHand.timeout()
+	r.handChanged()
+}
+
+// time bank back for everyone dealt into the hand, up to the room's time bank
+func (r *Room) refillTimeBanks(h *Hand) {
+	for _, hp := range h.Players {
+		if i := FindPlayerIndexInRoom(r, hp.ID); i >= 0 {
+			p := &r.players[i]
+			p.TimeBank = min(p.TimeBank+r.cfg.timeBankRefill(), r.cfg.timeBank())
+		}
+	}
+}
+
+// clockView fills in the countdown for clients: base time left, then the
+// acting player's time bank running down (also live in their roster entry)
+func (r *Room) clockView(st *RoomState) {
+	i := FindPlayerIndexInRoom(r, r.clockPlayer)
+	if r.clock == nil || i < 0 {
+		st.Hand.TimeRemaining = 0
+		return
+	}
+	if r.inTimeBank {
+		st.Hand.TimeRemaining = 0
+		st.Hand.UsingTimeBank = true
+		st.Players[i].TimeBank = max(0, time.Until(r.clockDeadline).Seconds())
+	}
+	st.Hand.TimeBank = st.Players[i].TimeBank
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeBankDrawsDownAfterBaseClock(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3")
+	r.startNextHandIfReady() // seat 1 to act
+
+	// base clock runs out, seat 1 is now on their time bank
+	r.clockExpired()
+	st := r.snapshot("")
+	if !st.Hand.UsingTimeBank || st.Hand.TimeRemaining != 0 || st.Hand.TimeBank <= 59 || st.Hand.TimeBank > 60 {
+		t.Fatalf("clock after base time: %+v", st.Hand)
+	}
+	if r.currentHand.actionPlayerIndex != 0 {
+		t.Fatalf("player should still be on the clock")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := r.action(Action{PlayerID: "1", Action: "call"}); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if bank := r.players[0].TimeBank; bank >= 59.97 || bank < 59 {
+		t.Fatalf("time bank after using some = %.3f", bank)
+	}
+	// next player starts on the base clock with a full bank
+	st = r.snapshot("")
+	if st.Hand.UsingTimeBank || st.Hand.TimeRemaining <= 0 || st.Hand.TimeBank != 60 {
+		t.Fatalf("next player's clock: %+v", st.Hand)
+	}
+}
+
+func TestTimeBankRunsOutAndRefills(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3")
+	r.cfg.TimeBankRefill = 5
+	r.startNextHandIfReady()
+
+	// base clock, then the whole time bank: seat 1 facing the big blind is folded
+	r.clockExpired()
+	r.clockExpired()
+	if !r.currentHand.Players[0].folded || r.players[0].TimeBank != 0 {
+		t.Fatalf("folded %v time bank %.1f, want folded with nothing left", r.currentHand.Players[0].folded, r.players[0].TimeBank)
+	}
+
+	// small blind folds too, the hand is over and everyone dealt in gets some time back
+	if err := r.action(Action{PlayerID: "2", Action: "fold"}); err != nil {
+		t.Fatalf("fold: %v", err)
+	}
+	if r.players[0].TimeBank != 5 || r.players[1].TimeBank != 60 {
+		t.Fatalf("time banks %.1f and %.1f, want 5 and capped at 60", r.players[0].TimeBank, r.players[1].TimeBank)
+	}
+}
+
+func TestClockKeepsRunningWhenOthersLeave(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3", "4")
+	r.startNextHandIfReady() // seat 4 to act
+
+	deadline := r.clockDeadline
+	time.Sleep(10 * time.Millisecond)
+	if err := r.leave("2"); err != nil {
+		t.Fatal(err)
+	}
+	if r.clockPlayer != "4" || !r.clockDeadline.Equal(deadline) {
+		t.Fatalf("clock for %s restarted when the small blind left", r.clockPlayer)
+	}
+
+	// same once they are into their time bank
+	r.clockExpired()
+	deadline = r.clockDeadline
+	time.Sleep(10 * time.Millisecond)
+	if err := r.leave("3"); err != nil {
+		t.Fatal(err)
+	}
+	if !r.inTimeBank || !r.clockDeadline.Equal(deadline) {
+		t.Fatalf("time bank in use %v, deadline moved %v", r.inTimeBank, r.clockDeadline.Sub(deadline))
+	}
+
+	// acting starts the next player's clock
+	if err := r.action(Action{PlayerID: "4", Action: "call"}); err != nil {
+		t.Fatal(err)
+	}
+	if r.clockPlayer != "1" || r.inTimeBank {
+		t.Fatalf("clock for %s after seat 4 called", r.clockPlayer)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Event is something that happened at a table, pushed to websocket clients as JSON.
+// only the fields that matter for the event type are set
+type Event struct {
+	Type     string     `json:"type"` // see the event types below
+	Room     int        `json:"room"`
+	PlayerID string     `json:"playerId,omitempty"`
+	Seat     int        `json:"seat,omitempty"`
+	Action   string     `json:"action,omitempty"`
+	Amount   Chips      `json:"amount,omitempty"`
+	Total    Chips      `json:"total,omitempty"` // actions: what the player has in on this street afterwards
+	AllIn    bool       `json:"allIn,omitempty"` // actions: this put the player all in
+	Pot      Chips      `json:"pot,omitempty"`
+	Street   string     `json:"street,omitempty"`
+	Cards    []Card     `json:"cards,omitempty"`
+	Winners  []string   `json:"winners,omitempty"`
+	Shares   []Chips    `json:"shares,omitempty"` // what each winner got, odd chips included
+	HandType HandType   `json:"handType,omitempty"`
+	Seconds  float64    `json:"seconds,omitempty"`
+	Reason   string     `json:"reason,omitempty"` // why a player was sat out or removed: "timeouts", "busted", "idle"
+	State    *RoomState `json:"state,omitempty"`
+	to       string     // player id for private events (hole cards), empty for everyone
+}
+
+// event types
+const (
+	EventSnapshot     = "snapshot"      // the table as the client sees it, on connecting and after anything changed
+	EventPlayerJoined = "player_joined" // someone sat down
+	EventPlayerLeft   = "player_left"
+	EventSitIn        = "sit_in"
+	EventSitOut       = "sit_out"
+	EventHandStarted  = "hand_started" // Seat is the button
+	EventCardsDealt   = "cards_dealt"  // private, the player's hole cards
+	EventAction       = "action"       // blinds, antes and every player action, Amount is chips put in
+	EventStreet       = "street"       // new street dealt, Cards is the whole board
+	EventShowdown     = "showdown"     // a player shows their cards
+	EventBetReturned  = "bet_returned" // the part of a bet nobody called goes back to the player, before the pots are paid
+	EventPotAwarded   = "pot_awarded"
+	EventTimer        = "timer"     // a player's clock started, Seconds to act
+	EventTimeBank     = "time_bank" // their clock ran out and their time bank started, Seconds left in it
+	EventHandOver     = "hand_over"
+	EventTableFrozen  = "table_frozen" // an audit check failed, Reason says which and why
+)
+
+// publish hands an event from the hand to whoever is listening (the room),
+// and keeps it in the hand's log for the hand history
+func (h *Hand) publish(e Event) {
+	h.log = append(h.log, e)
+	if h.emit != nil {
+		h.emit(e)
+	}
+}
+
+// a websocket connection listening to a room. playerID is set if the client
+// is a seated player, they also get that player's private events
+type wsClient struct {
+	playerID string
+	send     chan []byte
+}
+
+// broadcast sends an event to every client of the room (or only to its player for
+// private events). slow clients that can't keep up are dropped instead of blocking the room
+func (r *Room) broadcast(e Event) {
+	e.Room = r.id
+	msg, err := json.Marshal(e)
+	if err != nil {
+		fmt.Printf("can't encode %s event: %v\n", e.Type, err)
+		return
+	}
+	r.changed = true
+	for c := range r.clients {
+		if e.to != "" && c.playerID != e.to {
+			continue
+		}
+		select {
+		case c.send <- msg:
+		default:
+			r.unsubscribe(c)
+		}
+	}
+}
+
+// pushState follows the events of a command (or of the clock) with a fresh snapshot
+// for each client, their own cards and actions included, so nobody has to ask /state
+func (r *Room) pushState() {
+	if !r.changed {
+		return
+	}
+	r.changed = false
+	for c := range r.clients {
+		st := r.snapshot(c.playerID)
+		r.broadcastTo(c, Event{Type: EventSnapshot, State: &st})
+	}
+}
+
+func (r *Room) subscribe(c *wsClient) {
+	r.clients[c] = true
+
+	// catch the client up: the table as it is now and their own cards if they are in a hand
+	st := r.snapshot(c.playerID)
+	r.broadcastTo(c, Event{Type: EventSnapshot, State: &st})
+	if h := r.currentHand; h != nil && c.playerID != "" {
+		if i := FindPlayerIndexInHand(h, c.playerID); i >= 0 {
+			r.broadcastTo(c, Event{Type: EventCardsDealt, PlayerID: c.playerID, Seat: h.Players[i].Seat, Cards: h.Players[i].hand})
+		}
+	}
+}
+
+func (r *Room) unsubscribe(c *wsClient) {
+	if r.clients[c] {
+		delete(r.clients, c)
+		close(c.send)
+	}
+}
+
+// send one event to a single client
+func (r *Room) broadcastTo(c *wsClient, e Event) {
+	e.Room = r.id
+	msg, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		r.unsubscribe(c)
+	}
+}
@@ -2,3 +2,4 @@ module poker_app
 
 go 1.23.3
 
+require github.com/gorilla/websocket v1.5.3
@@ -2,37 +2,80 @@ package main
 
 import (
 	"fmt"
-	"math/rand"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 )
 
 type Action struct {
-	PlayerID string  `json:"playerId"`
-	Action   string  `json:"action"` // "raise", "call", "fold", "check"
-	Amount   float64 `json:"amount"`
+	PlayerID string `json:"playerId"`
+	Action   string `json:"action"` // "raise", "call", "fold", "check", "allin"
+	Amount   Chips  `json:"amount"` // for raise, the total bet this street to raise to
+}
+
+// where a hand is at, states only ever move forward
+type HandState int
+
+const (
+	StatePreFlop HandState = iota
+	StateFlop
+	StateTurn
+	StateRiver
+	StateShowdown
+	StateOver
+)
+
+func (s HandState) String() string {
+	switch s {
+	case StatePreFlop:
+		return "pre-flop"
+	case StateFlop:
+		return "flop"
+	case StateTurn:
+		return "turn"
+	case StateRiver:
+		return "river"
+	case StateShowdown:
+		return "showdown"
+	case StateOver:
+		return "over"
+	}
+	return "unknown"
 }
 
 type Hand struct {
+	id                string // "<room>-<hand number>"
+	started           time.Time
+	log               []Event // everything published, in order
+	chips             Chips   // everything on the table when the hand started, see checkChips
+	game              Variant // hole cards, hand ranking and betting structure
+	seed              Seed    // what the deck was shuffled with, kept so the hand can be replayed
 	Players           []Player
 	actionPlayerIndex int
 	deck              []Card
-	currentState      string // "pre-flop", "flop", "turn", "river", "showdown", "over"
+	currentState      HandState
 	board             []Card
-	pot               float64
-	avaliableActions  []string // "raise", "call", "fold", "check" (changes based on state)
-}
-
-func shuffleDeck(deck []Card) {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for i := len(deck) - 1; i > 0; i-- {
-		j := r.Intn(i + 1)
-		deck[i], deck[j] = deck[j], deck[i]
-	}
+	pot               Chips
+	pots              []Pot // main pot and side pots, filled in at showdown
+	dealerIndex       int
+	buttonSeat        int // may be an empty seat, then dealerIndex is the player before it
+	smallBlindIndex   int // -1 for a dead small blind
+	bigBlindIndex     int
+	smallBlind        Chips
+	bigBlind          Chips
+	ante              Chips
+	currentBet        Chips       // highest bet on the current street
+	lastRaise         Chips       // size of the last full bet or raise, the minimum raise increment
+	minBet            Chips       // smallest opening bet on a street
+	bets              int         // full bets and raises this street, for the fixed-limit cap
+	turns             int         // actions taken in turn, a new turn each time it goes up
+	avaliableActions  []string    // "raise", "call", "fold", "check", "allin" (computed for the acting player)
+	emit              func(Event) // where hand events go, nil if nobody is listening
 }
 
 func checkPlayerCanAct(H *Hand, p Player) bool {
-	return p.Stack > 0 && p.canAct
+	return p.Stack > 0 && p.canAct && !p.folded
 }
 
 func FindPlayerIndexInHand(H *Hand, id string) int {
@@ -55,115 +98,364 @@ func nextEligible(H *Hand, start int) int {
 	return -1
 }
 
-// take action from channel and do it (mutates H via pointer)
-func handleAction(H *Hand, action Action) {
-	// if action cannot be done, return
-	if !contains(H.avaliableActions, action.Action) {
-		return
+// newHand shuffles a fresh deck with seed, rooms pass randomSeed() and tests a fixed one
+func newHand(players []Player, pos Positions, smallBlind Chips, bigBlind Chips, ante Chips, seed Seed, game Variant) *Hand {
+	suits := []string{"S", "H", "D", "C"}
+	ranks := []string{"14", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}
+	if game.ShortDeck {
+		// six plus: the 2s to 5s come out, 36 cards
+		ranks = []string{"14", "6", "7", "8", "9", "10", "11", "12", "13"}
+	}
+	deck := make([]Card, 0, len(suits)*len(ranks))
+
+	for _, suit := range suits {
+		for _, rank := range ranks {
+			deck = append(deck, Card{Suit: suit, Rank: rank})
+		}
+	}
+	shuffleDeck(deck, seed)
+
+	// the smallest bet is one big blind (or one chip if there are no blinds)
+	minBet := max(bigBlind, Chip)
+
+	buttonSeat := pos.ButtonSeat
+	if buttonSeat == 0 && pos.Dealer >= 0 && pos.Dealer < len(players) {
+		buttonSeat = players[pos.Dealer].Seat
+	}
+
+	return &Hand{
+		Players:           players,
+		actionPlayerIndex: pos.BigBlind,
+		dealerIndex:       pos.Dealer,
+		buttonSeat:        buttonSeat,
+		smallBlindIndex:   pos.SmallBlind,
+		bigBlindIndex:     pos.BigBlind,
+		smallBlind:        smallBlind,
+		bigBlind:          bigBlind,
+		ante:              ante,
+		deck:              deck,
+		seed:              seed,
+		game:              game,
+		currentState:      StatePreFlop,
+		pot:               0,
+		minBet:            minBet,
+		lastRaise:         minBet,
 	}
+}
 
-	switch action.Action {
-	case "raise":
-		H.Players[H.actionPlayerIndex].Stack -= action.Amount
-		H.pot += action.Amount
-		H.avaliableActions = []string{"call", "fold", "raise"}
-		H.Players[H.actionPlayerIndex].canAct = false
+// a pot (main or side) and the players who can win it
+type Pot struct {
+	Amount   Chips
+	Eligible []int    // indexes into H.Players
+	Winners  []string // player ids, set once the pot is awarded
+}
 
-		// everyone still in hand can act again
-		for i := range H.Players {
-			H.Players[i].canAct = true
+// buildPots splits everything put in this hand into a main pot and side pots.
+// each contribution level caps what a shorter stack can win, folded players
+// add chips to the pots but are never eligible for them
+func buildPots(H *Hand) []Pot {
+	levels := make([]Chips, 0, len(H.Players))
+	for _, p := range H.Players {
+		if p.totalBet > 0 && !p.folded {
+			levels = append(levels, p.totalBet)
 		}
+	}
+	slices.Sort(levels)
 
-	case "call":
-		H.Players[H.actionPlayerIndex].Stack -= action.Amount
-		H.pot += action.Amount
-		H.Players[H.actionPlayerIndex].canAct = false
+	pots := []Pot{}
+	prev := Chips(0)
+	for _, level := range levels {
+		if level == prev {
+			continue
+		}
+		pot := Pot{}
+		for i, p := range H.Players {
+			pot.Amount += min(p.totalBet, level) - min(p.totalBet, prev)
+			if !p.folded && p.totalBet >= level {
+				pot.Eligible = append(pot.Eligible, i)
+			}
+		}
+		prev = level
+		pots = append(pots, pot)
+	}
 
-	case "fold":
+	// chips folded players put in above every live player's level go to the last pot
+	extra := Chips(0)
+	for _, p := range H.Players {
+		extra += max(p.totalBet-prev, 0)
+	}
+	if extra > 0 && len(pots) == 0 {
+		pot := Pot{}
 		for i, p := range H.Players {
-			if p.ID == action.PlayerID {
-				H.Players = append(H.Players[:i], H.Players[i+1:]...)
-				break
+			if !p.folded {
+				pot.Eligible = append(pot.Eligible, i)
 			}
 		}
+		pots = append(pots, pot)
 	}
+	if extra > 0 {
+		pots[len(pots)-1].Amount += extra
+	}
+	return pots
 }
 
-func newHand(players []Player, smallBlindPosition int) *Hand {
-	suits := []string{"S", "H", "D", "C"}
-	ranks := []string{"14", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}
-	deck := make([]Card, 0, 52)
+// splitPot divides amount between the winners. whole chips are split evenly and
+// the odd chips go one at a time to the winners closest to the left of the button.
+// winners is sorted into that order and what each got is returned in the same order
+func splitPot(H *Hand, amount Chips, winners []int) []Chips {
+	n := len(H.Players)
+	sort.Slice(winners, func(i, j int) bool {
+		return (winners[i]-H.dealerIndex-1+n)%n < (winners[j]-H.dealerIndex-1+n)%n
+	})
 
-	for _, suit := range suits {
-		for _, rank := range ranks {
-			deck = append(deck, Card{Suit: suit, Rank: rank})
+	count := Chips(len(winners))
+	share := amount / Chip / count * Chip
+	left := amount - share*count
+	shares := make([]Chips, count)
+	for i := range winners {
+		shares[i] = share
+		if left >= Chip {
+			shares[i] += Chip
+			left -= Chip
 		}
 	}
-	shuffleDeck(deck)
+	// anything smaller than a chip goes to the first winner
+	if left > 0 {
+		shares[0] += left
+	}
+	for i, w := range winners {
+		H.Players[w].Stack += shares[i]
+	}
+	return shares
+}
 
-	return &Hand{
-		Players:           players,
-		actionPlayerIndex: smallBlindPosition,
-		deck:              deck,
-		currentState:      "pre-flop",
-		pot:               0,
-		avaliableActions:  []string{"raise", "fold", "check"},
-	}
-}
-
-func streetLoop(h *Hand) {
-	for {
-		actingPlayerIndex := nextEligible(h, h.actionPlayerIndex)
-		if actingPlayerIndex == -1 {
-			break
-		}
-		println("player:", h.Players[actingPlayerIndex].ID, "is acting")
-		fmt.Printf("can do: %s\n", strings.Join(h.avaliableActions, ", "))
-
-		h.actionPlayerIndex = actingPlayerIndex
-		cur := &h.Players[actingPlayerIndex]
-
-		// wait until player's action or timeout (no polling)
-		var act Action
-		got := false
-		timer := time.NewTimer(30 * time.Second)
-		select {
-		case act = <-cur.pendingAction:
-			got = true
-		case <-timer.C:
-			if contains(h.avaliableActions, "check") {
-				act = Action{PlayerID: cur.ID, Action: "check"}
-				got = true
-			} else {
-				act = Action{PlayerID: cur.ID, Action: "fold"}
-				got = true
+// showdown ranks every live hand and awards each pot to the best eligible hand
+func showdown(H *Hand) {
+	returnUncalled(H)
+	H.pots = buildPots(H)
+
+	// everyone still in shows their cards when there is something to contest
+	if playersInHand(H) > 1 {
+		for _, p := range H.Players {
+			if !p.folded {
+				H.publish(Event{Type: EventShowdown, PlayerID: p.ID, Seat: p.Seat, Cards: p.hand, HandType: getPlayerBestHand(H, p).Type})
 			}
 		}
-		timer.Stop()
+	}
 
-		// if got no action check/fold
-		print("player ", cur.ID, " got action: ", act.Action, "\n")
-		if got && contains(h.avaliableActions, act.Action) && act.PlayerID == cur.ID {
-			handleAction(h, act)
-		} else if contains(h.avaliableActions, "check") {
-			handleAction(h, Action{PlayerID: cur.ID, Action: "check"})
-		} else {
-			handleAction(h, Action{PlayerID: cur.ID, Action: "fold"})
+	for i := range H.pots {
+		pot := &H.pots[i]
+		if len(pot.Eligible) == 0 {
+			continue
 		}
-		print("pot: ", h.pot, "\n")
 
-		cur.canAct = false
-		h.actionPlayerIndex = (h.actionPlayerIndex + 1) % len(h.Players)
+		// uncontested pots (everyone else folded or an uncalled bet) need no cards shown
+		if len(pot.Eligible) == 1 {
+			shares := splitPot(H, pot.Amount, pot.Eligible)
+			pot.Winners = []string{H.Players[pot.Eligible[0]].ID}
+			fmt.Printf("pot of %s won uncontested by %s\n", pot.Amount, pot.Winners[0])
+			H.publish(Event{Type: EventPotAwarded, Amount: pot.Amount, Winners: pot.Winners, Shares: shares})
+			continue
+		}
+
+		winners := []int{pot.Eligible[0]}
+		best := getPlayerBestHand(H, H.Players[pot.Eligible[0]])
+		for _, idx := range pot.Eligible[1:] {
+			hand := getPlayerBestHand(H, H.Players[idx])
+			switch compareHands(hand, best) {
+			case 1:
+				best = hand
+				winners = []int{idx}
+			case 0:
+				winners = append(winners, idx)
+			}
+		}
+
+		shares := splitPot(H, pot.Amount, winners)
+		for _, w := range winners {
+			pot.Winners = append(pot.Winners, H.Players[w].ID)
+		}
+		fmt.Printf("pot of %s won by %s with %s\n", pot.Amount, strings.Join(pot.Winners, ", "), best.Type)
+		H.publish(Event{Type: EventPotAwarded, Amount: pot.Amount, Winners: pot.Winners, Shares: shares, HandType: best.Type})
 	}
+	H.pot = 0
 }
 
-func showdown(H *Hand) {
-	for _, p := range H.Players {
-		p.Stack += H.pot / float64(len(H.Players))
+// returnUncalled gives back whatever the biggest bet still in the hand has over
+// everyone else's (folded players' included), nobody called that part so it was never in play
+func returnUncalled(H *Hand) {
+	top := -1
+	for i, p := range H.Players {
+		if !p.folded && (top < 0 || p.totalBet > H.Players[top].totalBet) {
+			top = i
+		}
+	}
+	if top < 0 {
+		return
+	}
+	next := Chips(0)
+	for i, p := range H.Players {
+		if i != top {
+			next = max(next, p.totalBet)
+		}
+	}
+	p := &H.Players[top]
+	extra := p.totalBet - next
+	if extra <= 0 {
+		return
+	}
+	p.totalBet -= extra
+	p.streetBet = max(p.streetBet-extra, 0)
+	p.Stack += extra
+	H.pot -= extra
+	H.publish(Event{Type: EventBetReturned, PlayerID: p.ID, Seat: p.Seat, Amount: extra, Pot: H.pot})
+}
+
+// deal one more street onto the board (burn first)
+func dealStreet(h *Hand, count int) {
+	h.deck = h.deck[1:] // burn
+	h.board = append(h.board, h.deck[:count]...)
+	h.deck = h.deck[count:]
+}
+
+// players who have not folded
+func playersInHand(h *Hand) int {
+	n := 0
+	for _, p := range h.Players {
+		if !p.folded {
+			n++
+		}
+	}
+	return n
+}
+
+// players who can still put chips in (not folded, not all in)
+func playersWithChips(h *Hand) int {
+	n := 0
+	for _, p := range h.Players {
+		if !p.folded && p.Stack > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// a betting round only happens if at least two players can still bet,
+// or the last player with chips still has to call an all in
+func bettingRoundNeeded(h *Hand) bool {
+	// everyone else folded (maybe out of turn, like a player leaving), nobody left to bet against
+	if playersInHand(h) <= 1 {
+		return false
+	}
+	switch playersWithChips(h) {
+	case 0:
+		return false
+	case 1:
+		for _, p := range h.Players {
+			if !p.folded && p.Stack > 0 {
+				return amountToCall(h, p) > 0
+			}
+		}
+	}
+	return true
+}
+
+// advance moves the hand to its next state once the current betting round is closed.
+// one player left ends the hand right away, otherwise the next street is dealt
+func advance(h *Hand) {
+	if playersInHand(h) <= 1 {
+		h.currentState = StateShowdown
+		return
+	}
+
+	switch h.currentState {
+	case StatePreFlop:
+		dealStreet(h, 3)
+		h.currentState = StateFlop
+	case StateFlop:
+		dealStreet(h, 1)
+		h.currentState = StateTurn
+	case StateTurn:
+		dealStreet(h, 1)
+		h.currentState = StateRiver
+	case StateRiver:
+		h.currentState = StateShowdown
+		return
 	}
+	debugf("%s: %d cards on board", h.currentState, len(h.board))
+	h.publish(Event{Type: EventStreet, Street: h.currentState.String(), Cards: append([]Card{}, h.board...), Pot: h.pot})
+
+	// new street: everyone left with chips acts again, starting left of the button
+	resetStreet(h)
+	h.actionPlayerIndex = (h.dealerIndex + 1) % len(h.Players)
 }
 
-func (h *Hand) run() {
+// postBlinds takes the antes and blinds before the cards are dealt.
+// a player who can't cover a blind or ante posts what they have and is all in
+func postBlinds(h *Hand) {
+	if len(h.Players) < 2 {
+		return
+	}
+	resetStreet(h)
+
+	// antes are dead money, they don't count towards anyone's bet this street
+	h.publish(Event{Type: EventHandStarted, Seat: h.buttonSeat})
+	for i := range h.Players {
+		a := min(h.ante, h.Players[i].Stack)
+		h.Players[i].Stack -= a
+		h.Players[i].totalBet += a
+		h.pot += a
+		if a > 0 {
+			h.publishAction(i, "ante", a)
+		}
+	}
+	if h.smallBlindIndex >= 0 {
+		before := h.Players[h.smallBlindIndex].Stack
+		commitChips(h, h.smallBlindIndex, h.smallBlind)
+		h.publishAction(h.smallBlindIndex, "small blind", before-h.Players[h.smallBlindIndex].Stack)
+	}
+	before := h.Players[h.bigBlindIndex].Stack
+	commitChips(h, h.bigBlindIndex, h.bigBlind)
+	h.publishAction(h.bigBlindIndex, "big blind", before-h.Players[h.bigBlindIndex].Stack)
+
+	// everyone has to call the full big blind even if it was posted short
+	h.currentBet = h.bigBlind
+	h.bets = 1
+	for i := range h.Players {
+		h.Players[i].canAct = !h.Players[i].folded && h.Players[i].Stack > 0
+	}
+	// pre-flop action starts left of the big blind (the button/small blind when heads up)
+	h.actionPlayerIndex = (h.bigBlindIndex + 1) % len(h.Players)
+	debugf("blinds posted: small %s, big %s, ante %s", h.smallBlind, h.bigBlind, h.ante)
+}
+
+// progress moves the hand forward until somebody has to act or the hand is over.
+// when it returns with the hand still running, actionPlayerIndex is the player
+// being waited on and avaliableActions is what they can do
+func progress(h *Hand) {
+	for h.currentState != StateOver {
+		switch h.currentState {
+		case StatePreFlop, StateFlop, StateTurn, StateRiver:
+			// when everyone is all in there is nothing to bet, the board just runs out
+			if bettingRoundNeeded(h) {
+				if i := nextEligible(h, h.actionPlayerIndex); i >= 0 {
+					h.actionPlayerIndex = i
+					h.avaliableActions = computeAvailableActions(h, i)
+					debugf("player %s is acting, can do: %s (to call %s)",
+						h.Players[i].ID, strings.Join(h.avaliableActions, ", "), amountToCall(h, h.Players[i]))
+					return
+				}
+			}
+			advance(h)
+		case StateShowdown:
+			showdown(h)
+			h.currentState = StateOver
+		}
+	}
+}
+
+// start deals the hole cards, posts the blinds and runs until the first player has to act
+func (h *Hand) start() {
 
 	//clear player cards
 	for i := range h.Players {
@@ -171,49 +463,73 @@ func (h *Hand) run() {
 	}
 	h.board = []Card{}
 	h.pot = 0
-	//deal players 2 cards, 1 card at a time
-	for i := 0; i < 2; i++ {
+	h.chips = tableChips(h)
+	h.started = time.Now()
+	postBlinds(h)
+	//deal players their hole cards, 1 card at a time
+	for i := 0; i < h.game.holeCards(); i++ {
 		for j := range h.Players {
 			h.Players[j].hand = append(h.Players[j].hand, h.deck[0])
 			h.deck = h.deck[1:]
 		}
 	}
-
-	// ===== PRE-FLOP =====
-	print("pre-flop\n")
-	if h.currentState == "pre-flop" {
-		streetLoop(h)
+	// hole cards only go to their owner
+	for _, p := range h.Players {
+		h.publish(Event{Type: EventCardsDealt, PlayerID: p.ID, Seat: p.Seat, Cards: p.hand, to: p.ID})
 	}
 
-	print("Pre-flop done, moving to flop\n")
-	// ===== FLOP =====
-	if h.currentState == "flop" {
-		h.deck = h.deck[1:]                      // burn
-		h.board = append(h.board, h.deck[:3]...) // flop
-		h.deck = h.deck[3:]
+	progress(h)
+}
 
-		streetLoop(h)
+// act applies an action from the player whose turn it is, then moves the hand on
+func (h *Hand) act(action Action) error {
+	if h.currentState == StateOver {
+		return fmt.Errorf("hand is over")
 	}
-
-	print("Flop done, moving to turn\n")
-	// ===== TURN =====
-	if h.currentState == "turn" {
-		h.deck = h.deck[1:]                  // burn
-		h.board = append(h.board, h.deck[0]) // turn
-		h.deck = h.deck[1:]
-
-		streetLoop(h)
+	i := h.actionPlayerIndex
+	before := h.pot
+	if err := handleAction(h, action); err != nil {
+		return err
 	}
+	h.publishAction(i, action.Action, h.pot-before)
+	h.turns++
+	debugf("player %s did: %s, pot: %s", action.PlayerID, action.Action, h.pot)
 
-	print("Turn done, moving to river\n")
-	// ===== RIVER =====
-	if h.currentState == "river" {
-		h.deck = h.deck[1:]                  // burn
-		h.board = append(h.board, h.deck[0]) // river
-		h.deck = h.deck[1:]
+	h.actionPlayerIndex = (h.actionPlayerIndex + 1) % len(h.Players)
+	progress(h)
+	return nil
+}
 
-		streetLoop(h)
+// tell listeners the player at index i did something, amount is the chips it cost them
+func (h *Hand) publishAction(i int, action string, amount Chips) {
+	p := h.Players[i]
+	h.publish(Event{Type: EventAction, PlayerID: p.ID, Seat: p.Seat, Action: action, Amount: amount,
+		Total: p.streetBet, AllIn: amount > 0 && p.Stack == 0, Pot: h.pot})
+}
+
+// forceFold folds a player whether or not it is their turn, used when they leave mid-hand
+func (h *Hand) forceFold(id string) {
+	i := FindPlayerIndexInHand(h, id)
+	if i < 0 || h.Players[i].folded || h.currentState == StateOver {
+		return
 	}
+	if i == h.actionPlayerIndex {
+		_ = h.act(Action{PlayerID: id, Action: "fold"})
+		return
+	}
+	h.Players[i].folded = true
+	h.Players[i].canAct = false
+	h.publishAction(i, "fold", 0)
+	// may have been the last player standing between someone and the pot
+	progress(h)
+}
 
-	//showdown
+// timeout acts for the player whose clock ran out: check if possible, fold otherwise
+func (h *Hand) timeout() {
+	cur := h.Players[h.actionPlayerIndex]
+	if contains(h.avaliableActions, "check") {
+		_ = h.act(Action{PlayerID: cur.ID, Action: "check"})
+	} else {
+		_ = h.act(Action{PlayerID: cur.ID, Action: "fold"})
+	}
 }
@@ -0,0 +1,142 @@
+package main
+
+import "testing"
+
+func TestShowdownSidePots(t *testing.T) {
+	// A is all in for 50 with the best hand, B and C keep betting for a side pot
+	h := &Hand{
+		Players: []Player{
+			{ID: "A", totalBet: 50 * Chip, hand: cards("14S 14H")},
+			{ID: "B", totalBet: 150 * Chip, hand: cards("13S 13H")},
+			{ID: "C", totalBet: 150 * Chip, hand: cards("12S 12H")},
+			{ID: "D", totalBet: 20 * Chip, folded: true, hand: cards("14D 14C")},
+		},
+		board: cards("2C 7D 9H 3S 4H"),
+		pot:   370,
+	}
+
+	showdown(h)
+
+	if len(h.pots) != 2 {
+		t.Fatalf("got %d pots, want 2", len(h.pots))
+	}
+	if h.pots[0].Amount != 170*Chip || h.pots[1].Amount != 200*Chip {
+		t.Fatalf("pots = %s / %s, want 170 / 200", h.pots[0].Amount, h.pots[1].Amount)
+	}
+	want := map[string]Chips{"A": 170 * Chip, "B": 200 * Chip, "C": 0, "D": 0}
+	for _, p := range h.Players {
+		if p.Stack != want[p.ID] {
+			t.Errorf("%s stack = %s, want %s", p.ID, p.Stack, want[p.ID])
+		}
+	}
+}
+
+func TestShowdownOddChip(t *testing.T) {
+	// both players play the board, the odd chip goes left of the button
+	h := &Hand{
+		Players: []Player{
+			{ID: "A", totalBet: 50 * Chip, hand: cards("2S 3H")},
+			{ID: "B", totalBet: 50 * Chip, hand: cards("2D 3C")},
+			{ID: "C", totalBet: Chip, folded: true},
+		},
+		board:       cards("14S 13S 12D 11C 10H"),
+		dealerIndex: 0,
+	}
+
+	showdown(h)
+
+	if h.Players[0].Stack != 50*Chip || h.Players[1].Stack != 51*Chip {
+		t.Fatalf("stacks = %s / %s, want 50 / 51", h.Players[0].Stack, h.Players[1].Stack)
+	}
+}
+
+func TestRunOutBoardWhenAllIn(t *testing.T) {
+	h := newHand([]Player{
+		{ID: "A", totalBet: 100 * Chip},
+		{ID: "B", totalBet: 100 * Chip},
+	}, Positions{Dealer: 0, SmallBlind: 0, BigBlind: 1}, 0, 0, 0, Seed{}, variants["holdem"])
+
+	h.start()
+
+	if h.currentState != StateOver {
+		t.Fatalf("state = %s, want over", h.currentState)
+	}
+	if len(h.board) != 5 {
+		t.Fatalf("board has %d cards, want 5", len(h.board))
+	}
+	if h.Players[0].Stack+h.Players[1].Stack != 200*Chip {
+		t.Fatalf("stacks add up to %s, want 200", h.Players[0].Stack+h.Players[1].Stack)
+	}
+}
+
+func TestHandEndsWhenOnePlayerLeft(t *testing.T) {
+	h := newHand([]Player{
+		{ID: "A", totalBet: 10 * Chip, Stack: 90 * Chip},
+		{ID: "B", totalBet: 5 * Chip, Stack: 95 * Chip, folded: true},
+	}, Positions{Dealer: 0, SmallBlind: 0, BigBlind: 1}, 0, 0, 0, Seed{}, variants["holdem"])
+
+	h.start()
+
+	if len(h.board) != 0 {
+		t.Fatalf("board has %d cards, want none", len(h.board))
+	}
+	if h.Players[0].Stack != 105*Chip {
+		t.Fatalf("A stack = %s, want 105", h.Players[0].Stack)
+	}
+}
+
+// the big blind leaves while the small blind is still to call: the small blind wins, nobody acts
+func TestForceFoldOutOfTurnEndsHand(t *testing.T) {
+	h := newHand([]Player{
+		{ID: "A", Stack: 100 * Chip},
+		{ID: "B", Stack: 100 * Chip},
+	}, Positions{Dealer: 0, SmallBlind: 0, BigBlind: 1}, Chip, 2*Chip, 0, Seed{}, variants["holdem"])
+	h.start()
+
+	h.forceFold("B")
+	if h.currentState != StateOver || h.Players[0].Stack != 102*Chip {
+		t.Fatalf("state %s, A stack %s, want over and 102", h.currentState, h.Players[0].Stack)
+	}
+}
+
+func TestPostBlindsAndAntes(t *testing.T) {
+	h := newHand([]Player{
+		{ID: "A", Stack: 100 * Chip},
+		{ID: "B", Stack: 100 * Chip},
+		{ID: "C", Stack: 3 * Chip / 2}, // can't cover the big blind after the ante
+		{ID: "D", Stack: 100 * Chip},
+	}, Positions{Dealer: 0, SmallBlind: 1, BigBlind: 2}, Chip, 2*Chip, Chip/2, Seed{}, variants["holdem"])
+
+	postBlinds(h)
+
+	if h.dealerIndex != 0 || h.Players[1].streetBet != Chip || h.Players[2].streetBet != Chip {
+		t.Fatalf("button %d, sb bet %s, bb bet %s", h.dealerIndex, h.Players[1].streetBet, h.Players[2].streetBet)
+	}
+	if h.Players[2].Stack != 0 {
+		t.Fatalf("short big blind should be all in, has %s", h.Players[2].Stack)
+	}
+	if h.pot != 4*Chip || h.currentBet != 2*Chip {
+		t.Fatalf("pot %s current bet %s, want 4 / 2", h.pot, h.currentBet)
+	}
+	// first to act is left of the big blind
+	if h.actionPlayerIndex != 3 {
+		t.Fatalf("first to act = %d, want 3", h.actionPlayerIndex)
+	}
+}
+
+func TestHeadsUpButtonPostsSmallBlind(t *testing.T) {
+	h := newHand([]Player{
+		{ID: "A", Stack: 100 * Chip},
+		{ID: "B", Stack: 100 * Chip},
+	}, Positions{Dealer: 1, SmallBlind: 1, BigBlind: 0}, Chip, 2*Chip, 0, Seed{}, variants["holdem"])
+
+	postBlinds(h)
+
+	if h.dealerIndex != 1 || h.Players[1].streetBet != Chip || h.Players[0].streetBet != 2*Chip {
+		t.Fatalf("heads up the button should post the small blind")
+	}
+	// button acts first pre-flop
+	if h.actionPlayerIndex != 1 {
+		t.Fatalf("first to act = %d, want the button", h.actionPlayerIndex)
+	}
+}
@@ -3,9 +3,20 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"os"
 	"strconv"
 )
 
+// POKER_DEBUG=1 traces every street and action of every hand to the log
+var debug = os.Getenv("POKER_DEBUG") != ""
+
+func debugf(format string, args ...any) {
+	if debug {
+		log.Printf(format, args...)
+	}
+}
+
 func room_request_to_int(s string) (int, error) {
 	if s == "" {
 		return 0, fmt.Errorf("missing required room id")
@@ -14,8 +25,8 @@ func room_request_to_int(s string) (int, error) {
 	if err != nil {
 		return 0, fmt.Errorf("invalid room id: %s", s)
 	}
-	if n != 1 && n != 2 {
-		return 0, fmt.Errorf("room id must be 1 or 2")
+	if n < 1 {
+		return 0, fmt.Errorf("invalid room id: %s", s)
 	}
 	return n, nil
 }
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// a finished hand as it is kept in hands.jsonl: who sat where with what, and
+// every event the hand published (blinds, hole cards, actions, streets, showdown, pots)
+type HandRecord struct {
+	ID         string         `json:"id"` // "<room>-<hand number>"
+	Room       int            `json:"room"`
+	Number     int            `json:"number"`
+	Started    time.Time      `json:"started"`
+	Ended      time.Time      `json:"ended"`
+	TableSeats int            `json:"tableSeats"`
+	Game       string         `json:"game,omitempty"`    // empty for hold'em hands recorded before there were other games
+	Betting    string         `json:"betting,omitempty"` // empty for the game's usual structure
+	ButtonSeat int            `json:"buttonSeat"`
+	SBSeat     int            `json:"smallBlindSeat"` // 0 for a dead small blind
+	BBSeat     int            `json:"bigBlindSeat"`
+	SmallBlind Chips          `json:"smallBlind"`
+	BigBlind   Chips          `json:"bigBlind"`
+	Ante       Chips          `json:"ante"`
+	Board      []Card         `json:"board"`
+	Players    []PlayerRecord `json:"players"` // in seat order
+	Pots       []PotRecord    `json:"pots"`
+	Events     []Event        `json:"events"`
+	Seed       string         `json:"seed,omitempty"` // hex, shuffles the deck again for replays
+}
+
+type PlayerRecord struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Seat       int    `json:"seat"`
+	Cards      []Card `json:"cards"`
+	StartStack Chips  `json:"startStack"`
+	EndStack   Chips  `json:"endStack"`
+	Folded     bool   `json:"folded"`
+}
+
+type PotRecord struct {
+	Amount  Chips    `json:"amount"`
+	Winners []string `json:"winners"`
+}
+
+// handRecord describes a finished hand, before reconcile so the roster still has the starting stacks
+func (r *Room) handRecord(h *Hand) HandRecord {
+	rec := HandRecord{
+		ID:         h.id,
+		Room:       r.id,
+		Number:     r.handCount,
+		Started:    h.started,
+		Ended:      time.Now(),
+		TableSeats: r.cfg.Seats,
+		Game:       h.game.Name,
+		Betting:    h.game.Betting,
+		ButtonSeat: h.buttonSeat, // may be an empty seat, a dead button
+		BBSeat:     h.Players[h.bigBlindIndex].Seat,
+		SmallBlind: h.smallBlind,
+		BigBlind:   h.bigBlind,
+		Ante:       h.ante,
+		Board:      append([]Card{}, h.board...),
+		Players:    []PlayerRecord{},
+		Pots:       []PotRecord{},
+		Events:     append([]Event{}, h.log...),
+		Seed:       h.seed.String(),
+	}
+	if h.smallBlindIndex >= 0 {
+		rec.SBSeat = h.Players[h.smallBlindIndex].Seat
+	}
+	for _, hp := range h.Players {
+		start := hp.Stack
+		if i := FindPlayerIndexInRoom(r, hp.ID); i >= 0 {
+			start = r.players[i].Stack
+		}
+		rec.Players = append(rec.Players, PlayerRecord{
+			ID:         hp.ID,
+			Name:       hp.Name,
+			Seat:       hp.Seat,
+			Cards:      append([]Card{}, hp.hand...),
+			StartStack: start,
+			EndStack:   hp.Stack,
+			Folded:     hp.folded,
+		})
+	}
+	for _, p := range h.pots {
+		rec.Pots = append(rec.Pots, PotRecord{Amount: p.Amount, Winners: append([]string{}, p.Winners...)})
+	}
+	return rec
+}
+
+// recordHand appends the finished hand to the store
+func (r *Room) recordHand(h *Hand) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.appendJSONL("hands.jsonl", r.handRecord(h)); err != nil {
+		fmt.Printf("can't record hand %s: %v\n", h.id, err)
+	}
+}
+
+// readHands returns the recorded hands keep says yes to, oldest first
+func (st *Store) readHands(keep func(HandRecord) bool) ([]HandRecord, error) {
+	hands := []HandRecord{}
+	err := st.readJSONL("hands.jsonl", func(line []byte) error {
+		var rec HandRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return err
+		}
+		if keep(rec) {
+			hands = append(hands, rec)
+		}
+		return nil
+	})
+	return hands, err
+}
+
+func (rec HandRecord) player(id string) (PlayerRecord, bool) {
+	for _, p := range rec.Players {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return PlayerRecord{}, false
+}
+
+/* === PokerStars style text === */
+
+// cards the way hand history tools expect them: [Ah Td]
+func starsCards(cards []Card) string {
+	faces := map[string]string{"10": "T", "11": "J", "12": "Q", "13": "K", "14": "A"}
+	out := make([]string, len(cards))
+	for i, c := range cards {
+		rank, ok := faces[c.Rank]
+		if !ok {
+			rank = c.Rank
+		}
+		out[i] = rank + strings.ToLower(c.Suit)
+	}
+	return "[" + strings.Join(out, " ") + "]"
+}
+
+// text renders the hand as a PokerStars hand history. with a viewer only their hole
+// cards are dealt face up (and whatever is shown at showdown), without one everyone's are
+func (rec HandRecord) text(viewer string) string {
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+	name := map[string]string{}
+	for _, p := range rec.Players {
+		name[p.ID] = p.Name
+	}
+
+	game, _ := variantFor(rec.Game, rec.Betting)
+	line("PokerStars Hand #%d%06d: %s (%s/%s) - %s", rec.Room, rec.Number, game.title(),
+		rec.SmallBlind, rec.BigBlind, rec.Started.UTC().Format("2006/01/02 15:04:05 UTC"))
+	line("Table 'Room %d' %d-max Seat #%d is the button", rec.Room, rec.TableSeats, rec.ButtonSeat)
+	for _, p := range rec.Players {
+		line("Seat %d: %s (%s in chips)", p.Seat, p.Name, p.StartStack)
+	}
+
+	// walk the log keeping track of the bet to match on each street
+	street := "Preflop"
+	streetMax := Chips(0)
+	holeCards := false
+	role := map[string]string{} // "small blind" / "big blind"
+	foldedOn := map[string]string{}
+	showed := map[string]Event{}
+	won := map[string]Chips{}
+	for _, e := range rec.Events {
+		who := name[e.PlayerID]
+		switch e.Type {
+		case EventAction:
+			allIn := ""
+			if e.AllIn {
+				allIn = " and is all-in"
+			}
+			switch e.Action {
+			case "ante":
+				line("%s: posts the ante %s%s", who, e.Amount, allIn)
+			case "small blind", "big blind":
+				role[e.PlayerID] = e.Action
+				line("%s: posts %s %s%s", who, e.Action, e.Amount, allIn)
+				streetMax = max(streetMax, e.Total)
+			case "fold":
+				foldedOn[e.PlayerID] = street
+				line("%s: folds", who)
+			case "check":
+				line("%s: checks", who)
+			case "call":
+				line("%s: calls %s%s", who, e.Amount, allIn)
+			default: // raise, allin
+				switch {
+				case e.Total <= streetMax:
+					line("%s: calls %s%s", who, e.Amount, allIn)
+				case streetMax == 0:
+					line("%s: bets %s%s", who, e.Amount, allIn)
+				default:
+					line("%s: raises %s to %s%s", who, e.Total-streetMax, e.Total, allIn)
+				}
+				streetMax = max(streetMax, e.Total)
+			}
+		case EventCardsDealt:
+			if !holeCards {
+				line("*** HOLE CARDS ***")
+				holeCards = true
+			}
+			if viewer == "" || viewer == e.PlayerID {
+				line("Dealt to %s %s", who, starsCards(e.Cards))
+			}
+		case EventStreet:
+			streetMax = 0
+			n := len(e.Cards)
+			switch n {
+			case 3:
+				street = "Flop"
+				line("*** FLOP *** %s", starsCards(e.Cards))
+			case 4:
+				street = "Turn"
+				line("*** TURN *** %s %s", starsCards(e.Cards[:3]), starsCards(e.Cards[3:]))
+			case 5:
+				street = "River"
+				line("*** RIVER *** %s %s", starsCards(e.Cards[:4]), starsCards(e.Cards[4:]))
+			}
+		case EventShowdown:
+			if len(showed) == 0 {
+				line("*** SHOW DOWN ***")
+			}
+			showed[e.PlayerID] = e
+			line("%s: shows %s (%s)", who, starsCards(e.Cards), e.HandType)
+		case EventBetReturned:
+			line("Uncalled bet (%s) returned to %s", e.Amount, who)
+		case EventPotAwarded:
+			for i, w := range e.Winners {
+				got := e.Amount / Chips(len(e.Winners))
+				if i < len(e.Shares) {
+					got = e.Shares[i]
+				}
+				won[w] += got
+				line("%s collected %s from pot", name[w], got)
+			}
+		}
+	}
+
+	line("*** SUMMARY ***")
+	total := Chips(0)
+	for _, p := range rec.Pots {
+		total += p.Amount
+	}
+	line("Total pot %s | Rake 0", total)
+	if len(rec.Board) > 0 {
+		line("Board %s", starsCards(rec.Board))
+	}
+	for _, p := range rec.Players {
+		tag := ""
+		if p.Seat == rec.ButtonSeat {
+			tag += " (button)"
+		}
+		if r := role[p.ID]; r != "" {
+			tag += " (" + r + ")"
+		}
+		var result string
+		switch sd, ok := showed[p.ID]; {
+		case ok && won[p.ID] > 0:
+			result = fmt.Sprintf("showed %s and won (%s) with %s", starsCards(sd.Cards), won[p.ID], sd.HandType)
+		case ok:
+			result = fmt.Sprintf("showed %s and lost with %s", starsCards(sd.Cards), sd.HandType)
+		case won[p.ID] > 0:
+			result = fmt.Sprintf("collected (%s)", won[p.ID])
+		case foldedOn[p.ID] == "Preflop":
+			result = "folded before Flop"
+		case foldedOn[p.ID] != "":
+			result = "folded on the " + foldedOn[p.ID]
+		default:
+			result = "mucked"
+		}
+		line("Seat %d: %s%s %s", p.Seat, p.Name, tag, result)
+	}
+	return b.String()
+}
+
+// a session's hands for import into tracking tools, from the player's side of the table
+// GET /history?room=1&playerId=2 with the player's token -> text/plain, newest last
+func (s *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	roomID, err := room_request_to_int(r.URL.Query().Get("room"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := r.URL.Query().Get("playerId")
+	// the room may be closed by now, so only the token itself is checked
+	if _, err := s.auth.verify(bearer(r), roomID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if s.store == nil {
+		http.Error(w, "hand history is not being kept", http.StatusNotFound)
+		return
+	}
+	hands, err := s.store.readHands(func(rec HandRecord) bool {
+		_, played := rec.player(id)
+		return rec.Room == roomID && played
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, rec := range hands {
+		_, _ = w.Write([]byte(rec.text(id) + "\n\n"))
+	}
+}
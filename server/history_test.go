@@ -0,0 +1,159 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// play one hand to showdown in a room that records hands, return its record
+func playRecordedHand(t *testing.T) HandRecord {
+	t.Helper()
+	r := seatedRoom(t, "1", "2", "3")
+	st, err := openStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.store = st
+	r.seeds = func() Seed { return Seed{7} }
+	r.startNextHandIfReady() // button seat 1, blinds seats 2 and 3
+
+	steps := []Action{
+		{PlayerID: "1", Action: "raise", Amount: 6 * Chip},
+		{PlayerID: "2", Action: "fold"},
+		{PlayerID: "3", Action: "call"},
+	}
+	// then check it down: seat 3 acts first after the flop
+	for i := 0; i < 3; i++ {
+		steps = append(steps, Action{PlayerID: "3", Action: "check"}, Action{PlayerID: "1", Action: "check"})
+	}
+	for _, a := range steps {
+		if err := r.action(a); err != nil {
+			t.Fatalf("%s %s: %v", a.PlayerID, a.Action, err)
+		}
+	}
+	hands, err := st.readHands(func(HandRecord) bool { return true })
+	if err != nil || len(hands) != 1 {
+		t.Fatalf("recorded %d hands (%v), want 1", len(hands), err)
+	}
+	return hands[0]
+}
+
+func TestHandRecordHasEventLog(t *testing.T) {
+	rec := playRecordedHand(t)
+	if rec.ID != "1-1" || len(rec.Board) != 5 || len(rec.Players) != 3 || rec.ButtonSeat != 1 || rec.Seed != (Seed{7}).String() {
+		t.Fatalf("record = %+v", rec)
+	}
+	seen := map[string]int{}
+	for _, e := range rec.Events {
+		seen[e.Type]++
+	}
+	want := map[string]int{EventHandStarted: 1, EventCardsDealt: 3, EventStreet: 3, EventShowdown: 2, EventPotAwarded: 1}
+	for typ, n := range want {
+		if seen[typ] != n {
+			t.Errorf("%d %s events, want %d", seen[typ], typ, n)
+		}
+	}
+	// blinds, three pre-flop actions and six checks
+	if seen[EventAction] != 11 {
+		t.Errorf("%d action events, want 11", seen[EventAction])
+	}
+}
+
+func TestPokerStarsText(t *testing.T) {
+	rec := playRecordedHand(t)
+	text := rec.text("1")
+
+	for _, want := range []string{
+		"PokerStars Hand #1000001: Hold'em No Limit (1/2) - ",
+		"Table 'Room 1' 6-max Seat #1 is the button",
+		"Seat 2: p2 (100 in chips)",
+		"p2: posts small blind 1",
+		"p3: posts big blind 2",
+		"*** HOLE CARDS ***",
+		"Dealt to p1 [",
+		"p1: raises 4 to 6",
+		"p2: folds",
+		"p3: calls 4",
+		"*** FLOP *** [",
+		"*** RIVER *** [",
+		"p3: checks",
+		"*** SHOW DOWN ***",
+		"Total pot 13 | Rake 0",
+		"Seat 2: p2 (small blind) folded before Flop",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in:\n%s", want, text)
+		}
+	}
+	// only the viewer's cards are dealt face up
+	if strings.Contains(text, "Dealt to p3") || !strings.Contains(rec.text(""), "Dealt to p3 [") {
+		t.Errorf("dealt cards shown to the wrong players:\n%s", text)
+	}
+	// somebody won the 13
+	if !strings.Contains(text, "and won (13) with") && !strings.Contains(text, "and won (6.5) with") {
+		t.Errorf("no winner in summary:\n%s", text)
+	}
+}
+
+// the small blind leaves, so the next button is dead on their empty seat. then a
+// raise nobody calls: the part over the big blind goes back and isn't in the pot
+func TestUncalledBetAndDeadButtonText(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3", "4")
+	st, err := openStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.store = st
+	r.seeds = func() Seed { return Seed{7} }
+	r.startNextHandIfReady() // button seat 1, blinds seats 2 and 3
+	if err := r.leave("2"); err != nil {
+		t.Fatal(err)
+	}
+	steps := []Action{
+		{PlayerID: "4", Action: "fold"},
+		{PlayerID: "1", Action: "fold"},
+		// next hand: button on empty seat 2, blinds seats 3 and 4
+		{PlayerID: "1", Action: "raise", Amount: 6 * Chip},
+		{PlayerID: "3", Action: "fold"},
+		{PlayerID: "4", Action: "fold"},
+	}
+	for _, a := range steps {
+		if err := r.action(a); err != nil {
+			t.Fatalf("%s %s: %v", a.PlayerID, a.Action, err)
+		}
+	}
+	hands, err := st.readHands(func(HandRecord) bool { return true })
+	if err != nil || len(hands) != 2 {
+		t.Fatalf("recorded %d hands (%v), want 2", len(hands), err)
+	}
+	rec := hands[1]
+	if rec.ButtonSeat != 2 || rec.SBSeat != 3 || rec.BBSeat != 4 {
+		t.Fatalf("button %d, blinds %d and %d, want 2, 3 and 4", rec.ButtonSeat, rec.SBSeat, rec.BBSeat)
+	}
+	for _, e := range rec.Events {
+		if e.Type == EventHandStarted && e.Seat != 2 {
+			t.Errorf("hand_started says the button is on seat %d, want 2", e.Seat)
+		}
+	}
+	text := rec.text("")
+	for _, want := range []string{
+		"Table 'Room 1' 6-max Seat #2 is the button",
+		"p1: raises 4 to 6",
+		"Uncalled bet (4) returned to p1",
+		"p1 collected 5 from pot",
+		"Total pot 5 | Rake 0",
+		"Seat 1: p1 collected (5)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in:\n%s", want, text)
+		}
+	}
+	if p, _ := rec.player("1"); p.EndStack-p.StartStack != 3*Chip {
+		t.Errorf("p1 won %s, want 3", p.EndStack-p.StartStack)
+	}
+	for _, h := range hands {
+		if _, err := replayHand(h, ""); err != nil {
+			t.Errorf("replay %s: %v", h.ID, err)
+		}
+	}
+}
@@ -5,21 +5,20 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 )
 
 /* === HTTP server handlers === */
 
-// the server containing all rooms
+// the server containing all rooms, handlers find them by id (see lobby.go)
 type Server struct {
-	room1 *Room
-	room2 *Room
-}
-
-func (s *Server) getRoom(q string) *Room {
-	if q == "2" {
-		return s.room2
-	}
-	return s.room1
+	mu       sync.Mutex // guards rooms and nextID, each room guards itself
+	rooms    map[int]*Room
+	nextID   int
+	auth     *Auth
+	bank     *Bank  // bankrolls, shared by every room
+	store    *Store // nil to keep everything in memory only
+	adminKey string // POKER_ADMIN_KEY, needed to grant chips and to open or close rooms, empty turns those off
 }
 
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -30,8 +29,16 @@ for users to join a room, if valid sends a command to the command channel of tha
 	request would have the form
 
 	curl -X POST "http://localhost:8080/join?room=1" \
-	  -H "Content-Type: application/json" \
-	  -d '{"id":"1234","name":"Alice","stack":100}'
+	  -H "Content-Type: application/json" -H "Authorization: Bearer <account secret>" \
+	  -d '{"id":"1234","name":"Alice","stack":100,"seat":3}'
+
+	seat is optional, without it the player gets the lowest free seat
+	stack is the buy in, it comes out of the player's bankroll and goes back when they leave.
+	the secret is the one POST /accounts gave out for the id, nobody else can spend that bankroll
+
+	the response has a session token for the player: {"playerId":"1234","room":1,"token":"..."}
+	/action, /leave and /sitInOrOut need it as "Authorization: Bearer <token>", so does
+	asking for your own cards on /state. /ws takes it as ?token=<token> instead
 */
 func (s *Server) joinHandler(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
@@ -46,13 +53,16 @@ func (s *Server) joinHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	p := newPlayer(tmp.ID, tmp.Name, tmp.Stack)
-	// has to be a valid room id
-	roomID, err := room_request_to_int(req.URL.Query().Get("room"))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	p.Seat = tmp.Seat
+	if err := s.bank.check(p.ID, bearer(req)); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	// has to be a room that exists
+	rm, ok := s.roomFromRequest(w, req)
+	if !ok {
 		return
 	}
-	rm := s.getRoom(fmt.Sprint(roomID))
 
 	//check if id is an int
 	if _, err := strconv.Atoi(p.ID); err != nil {
@@ -60,36 +70,33 @@ func (s *Server) joinHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// check if player already exists in that room
-	if rm.has(p.ID) {
-		http.Error(w, "player id already in room", http.StatusBadRequest)
+	//stack must be positive and at least minStack and not greater than maxStack
+	if p.Stack < rm.cfg.MinStack || p.Stack > rm.cfg.MaxStack {
+		http.Error(w, fmt.Sprintf("stack must be within %s and %s", rm.cfg.MinStack, rm.cfg.MaxStack), http.StatusBadRequest)
 		return
 	}
-	// check if name is already in that room
-	for _, pl := range rm.players {
-		if pl.Name == p.Name {
-			http.Error(w, "name already in room", http.StatusBadRequest)
-			return
-		}
-	}
-	//check if room has less than 9 players
-	if len(rm.players) >= 9 {
-		http.Error(w, "room is full", http.StatusBadRequest)
+	// seat is optional (0 = next free seat) but has to exist
+	if p.Seat < 0 || p.Seat > rm.cfg.Seats {
+		http.Error(w, fmt.Sprintf("seat must be between 1 and %d", rm.cfg.Seats), http.StatusBadRequest)
 		return
 	}
 
-	//stack must be positive and at least minStack and not greater than maxStack
-	print("stack: ", p.Stack, "minStack: ", p.Stack, rm.minStack)
-	if p.Stack < rm.minStack || p.Stack > rm.maxStack {
-		http.Error(w, fmt.Sprintf("stack must be within %f and %f", rm.minStack, rm.maxStack), http.StatusBadRequest)
+	// add player, the room checks the id, name and seat are free
+	p.canAct = true
+	token, claims := s.auth.issue(rm.id, p.ID)
+	p.session = claims.Session
+	if resp := rm.send(Command{Kind: "join", Player: p}); resp.Err != nil {
+		http.Error(w, resp.Err.Error(), errorStatus(resp.Err, http.StatusBadRequest))
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(JoinResponse{PlayerID: p.ID, Room: rm.id, Token: token})
+}
 
-	// add player
-	p.canAct = true
-	rm.joinAndLeaveChan <- Command{Kind: "join", Player: p}
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("joined\n"))
+type JoinResponse struct {
+	PlayerID string `json:"playerId"`
+	Room     int    `json:"room"`
+	Token    string `json:"token"`
 }
 
 // for users to leave a room, if valid sends a command to the command channel of that room, same format as join
@@ -104,8 +111,18 @@ func (s *Server) leaveHandler(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, "bad json (need id)", http.StatusBadRequest)
 		return
 	}
-	rm := s.getRoom(req.URL.Query().Get("room"))
-	rm.joinAndLeaveChan <- Command{Kind: "leave", Player: p}
+	rm, ok := s.roomFromRequest(w, req)
+	if !ok {
+		return
+	}
+	session, ok := s.authorize(w, req, rm.id, p.ID)
+	if !ok {
+		return
+	}
+	if resp := rm.send(Command{Kind: "leave", Player: p, Session: session}); resp.Err != nil {
+		http.Error(w, resp.Err.Error(), errorStatus(resp.Err, http.StatusConflict))
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("left\n"))
 }
@@ -115,6 +132,7 @@ func (s *Server) leaveHandler(w http.ResponseWriter, req *http.Request) {
 // /////////////////////////////////////////////////////////////////////////////////////////////////////////////
 type PlayersResponse struct {
 	Count   int      `json:"count"`
+	Seats   int      `json:"seats"`
 	Players []Player `json:"players"`
 	Room    int      `json:"room"`
 }
@@ -126,19 +144,22 @@ func (s *Server) playersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// parse and validate room id
-	roomID, err := room_request_to_int(r.URL.Query().Get("room"))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	rm, ok := s.roomFromRequest(w, r)
+	if !ok {
 		return
 	}
-
-	rm := s.getRoom(r.URL.Query().Get("room"))
+	res := rm.send(Command{Kind: "players"})
+	if res.Err != nil {
+		http.Error(w, res.Err.Error(), http.StatusNotFound)
+		return
+	}
+	st := res.State
 
 	resp := PlayersResponse{
-		Count:   len(rm.players),
-		Players: rm.players,
-		Room:    roomID,
+		Count:   len(st.Players),
+		Seats:   st.Seats,
+		Players: st.Players,
+		Room:    rm.id,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -149,28 +170,36 @@ func (s *Server) playersHandler(w http.ResponseWriter, r *http.Request) {
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // for return state of room to client
-// GET /state?room=1  -> { room, actionPlayerIndex, players }
+// GET /state?room=1&playerId=2  -> { room, actionPlayerIndex, actionSeat, buttonSeat, seats, players, hand, you }
+// hand is the public hand state (board, pots, bets, clock), you is only there for a
+// player dealt into the hand and holds their cards and legal actions
 func (s *Server) stateHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO var hand = s.getRoom(r.URL.Query().Get("room")).currentHand
 	if r.Method != http.MethodGet {
 		http.Error(w, "use GET", http.StatusMethodNotAllowed)
 		return
 	}
-	roomID, err := room_request_to_int(r.URL.Query().Get("room"))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	rm, ok := s.roomFromRequest(w, r)
+	if !ok {
+		return
+	}
+	// your own cards need your token, without a playerId it is the public view
+	cmd := Command{Kind: "state", Player: Player{ID: r.URL.Query().Get("playerId")}}
+	if cmd.Player.ID != "" {
+		session, ok := s.authorize(w, r, rm.id, cmd.Player.ID)
+		if !ok {
+			return
+		}
+		cmd.Session = session
+	}
+	resp := rm.send(cmd)
+	if resp.Err != nil {
+		http.Error(w, resp.Err.Error(), errorStatus(resp.Err, http.StatusBadRequest))
 		return
 	}
-	rm := s.getRoom(fmt.Sprint(roomID))
+	st := resp.State
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(struct {
-		Room              int      `json:"room"`
-		ActionPlayerIndex int      `json:"actionPlayerIndex"`
-		Players           []Player `json:"players"`
-	}{
-		Room: rm.id, ActionPlayerIndex: rm.smallBlindPosition + 1, Players: rm.players,
-	})
+	_ = json.NewEncoder(w).Encode(st)
 }
 
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -178,41 +207,23 @@ func (s *Server) stateHandler(w http.ResponseWriter, r *http.Request) {
 // exaample request
 /*POST http://localhost:8080/action?room=1
 Content-Type: application/json
+Authorization: Bearer <token from /join>
 {
   "playerId": "123",
   "action": "fold"
 }
 */
 
-// for rmaking the latest action the current action in the channel
-func enqueueLatest(ch chan Action, a Action) {
-	for {
-		select {
-		case ch <- a:
-			// sent successfully; done
-			return
-		case <-ch:
-			// channel was full; drop the value; and repeat statement to do first case
-		}
-	}
-}
 func (s *Server) setActionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "use POST", http.StatusMethodNotAllowed)
 		return
 	}
 	// check valid room
-	roomID, err := room_request_to_int(r.URL.Query().Get("room"))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	rm, ok := s.roomFromRequest(w, r)
+	if !ok {
 		return
 	}
-	rm := s.getRoom(fmt.Sprint(roomID))
-	if rm == nil || rm.currentHand == nil {
-		http.Error(w, "no active hand", http.StatusConflict)
-		return
-	}
-	h := rm.currentHand
 
 	// decode body
 	var a Action
@@ -220,25 +231,26 @@ func (s *Server) setActionHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad json (need playerId, action)", http.StatusBadRequest)
 		return
 	}
-	// find player
-	idx := FindPlayerIndexInHand(h, a.PlayerID)
-	if idx < 0 {
-		http.Error(w, "unknown player", http.StatusBadRequest)
+
+	session, ok := s.authorize(w, r, rm.id, a.PlayerID)
+	if !ok {
 		return
 	}
 
-	// enqueue latest action into channel
-	p := &h.Players[idx]
-	enqueueLatest(p.pendingAction, a)
+	// the room applies it right away if it is this player's turn and the action is legal
+	if resp := rm.send(Command{Kind: "action", Action: a, Session: session}); resp.Err != nil {
+		http.Error(w, resp.Err.Error(), errorStatus(resp.Err, http.StatusConflict))
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("action queued\n"))
+	_, _ = w.Write([]byte("action done\n"))
 }
 
 // /////////////////////////////////////////////////////////////////////////////////////////////////////////////
 // /////////////////////////////////////////////////////////////////////////////////////////////////////////////
 // need id of player
-// format is :8080/sitInOrOut?room=1&playerId=2&sitIn=true
+// format is :8080/sitInOrOut?room=1&playerId=2&sitIn=true with the player's token
 func (s *Server) sitInOrOutHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "use POST", http.StatusMethodNotAllowed)
@@ -246,44 +258,26 @@ func (s *Server) sitInOrOutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// check valid room
-	roomID, err := room_request_to_int(r.URL.Query().Get("room"))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	rm, ok := s.roomFromRequest(w, r)
+	if !ok {
 		return
 	}
 
-	// check if player is in room
-	rm := s.getRoom(fmt.Sprint(roomID))
-	if !rm.has(r.URL.Query().Get("playerId")) {
-		http.Error(w, "player not in room", http.StatusConflict)
+	// players in a hand sit out once it is over
+	sitIn := r.URL.Query().Get("sitIn")
+	if sitIn != "true" && sitIn != "false" {
+		http.Error(w, "sitIn must be true or false", http.StatusBadRequest)
 		return
 	}
-	// check if player is in a hand, if they are they can not sit in or out
-	h := rm.currentHand
-	p := &rm.players[FindPlayerIndexInRoom(rm, r.URL.Query().Get("playerId"))]
-
-	if h == nil {
-		if r.URL.Query().Get("sitIn") == "true" && p.sittingOut {
-			p.sittingOut = false
-		} else if r.URL.Query().Get("sitIn") == "false" && !p.sittingOut {
-			p.sittingOut = true
-		} else {
-			http.Error(w, "already in that state", http.StatusBadRequest)
-			return
-		}
-	} else if FindPlayerIndexInHand(h, r.URL.Query().Get("playerId")) >= 0 {
-		http.Error(w, "player already in hand", http.StatusConflict)
+	id := r.URL.Query().Get("playerId")
+	session, ok := s.authorize(w, r, rm.id, id)
+	if !ok {
+		return
+	}
+	cmd := Command{Kind: "sit", Player: Player{ID: id}, SitIn: sitIn == "true", Session: session}
+	if resp := rm.send(cmd); resp.Err != nil {
+		http.Error(w, resp.Err.Error(), errorStatus(resp.Err, http.StatusConflict))
 		return
-	} else {
-
-		if r.URL.Query().Get("sitIn") == "true" && p.sittingOut {
-			p.sittingOut = false
-		} else if r.URL.Query().Get("sitIn") == "false" && !p.sittingOut {
-			p.sittingOut = true
-		} else {
-			http.Error(w, "already in that state", http.StatusBadRequest)
-			return
-		}
 	}
 
 	w.WriteHeader(http.StatusOK)
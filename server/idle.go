@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// players who keep timing out are sat out, and players who stay sat out too
+// long are removed from the room and take their stack with them
+
+const (
+	defaultMaxTimeouts = 2                // timeouts in a row before being sat out
+	defaultMaxSitOut   = 10 * time.Minute // sitting out before being removed
+)
+
+func (c RoomConfig) maxTimeouts() int {
+	if c.MaxTimeouts <= 0 {
+		return defaultMaxTimeouts
+	}
+	return c.MaxTimeouts
+}
+
+func (c RoomConfig) maxSitOut() time.Duration {
+	if c.MaxSitOut <= 0 {
+		return defaultMaxSitOut
+	}
+	return seconds(c.MaxSitOut)
+}
+
+// timedOut counts a timeout against a player, once they hit the limit they sit
+// out when the hand is over (disconnected players end up here too)
+func (r *Room) timedOut(id string) {
+	i := FindPlayerIndexInRoom(r, id)
+	if i < 0 {
+		return
+	}
+	p := &r.players[i]
+	p.timeouts++
+	if p.timeouts >= r.cfg.maxTimeouts() && !p.sitOutNext {
+		fmt.Printf("player %s timed out %d times in a row, sitting them out\n", p.ID, p.timeouts)
+		p.sitOutNext = true
+		p.sitOutReason = "timeouts"
+	}
+}
+
+// removeIdle takes players who have been sitting out too long off the roster,
+// their stack goes back to their bankroll
+func (r *Room) removeIdle() {
+	kept := r.players[:0]
+	for _, p := range r.players {
+		inHand := r.currentHand != nil && FindPlayerIndexInHand(r.currentHand, p.ID) >= 0
+		if p.SittingOut && !inHand && time.Since(p.sitOutSince) > r.cfg.maxSitOut() {
+			fmt.Printf("player %s sat out too long, removed from room %d with %s\n", p.ID, r.id, p.Stack)
+			r.cashOut(p, "idle")
+			continue
+		}
+		kept = append(kept, p)
+	}
+	if len(kept) < len(r.players) {
+		r.players = kept
+		r.save()
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// the clock runs out for whoever is acting: base time, then time bank if they have any
+func expireClock(r *Room) {
+	r.clockExpired()
+	if r.inTimeBank {
+		r.clockExpired()
+	}
+}
+
+// a websocket client with room for everything the test will see
+func listen(r *Room) *wsClient {
+	c := &wsClient{send: make(chan []byte, 256)}
+	r.clients[c] = true
+	return c
+}
+
+func received(c *wsClient, typ string) []Event {
+	var got []Event
+	for {
+		select {
+		case msg := <-c.send:
+			var e Event
+			_ = json.Unmarshal(msg, &e)
+			if e.Type == typ {
+				got = append(got, e)
+			}
+		default:
+			return got
+		}
+	}
+}
+
+func TestConsecutiveTimeoutsSitPlayerOut(t *testing.T) {
+	r := seatedRoom(t, "1", "2")
+	r.cfg.MaxTimeouts = 3
+	c := listen(r)
+	r.startNextHandIfReady()
+
+	// player 1 is away, player 2 checks or calls whenever it is their turn
+	for n := 0; n < 50 && !r.players[0].SittingOut; n++ {
+		h := r.currentHand
+		if h == nil {
+			t.Fatalf("no hand running")
+		}
+		if h.Players[h.actionPlayerIndex].ID == "1" {
+			expireClock(r)
+			continue
+		}
+		kind := "check"
+		if !contains(h.avaliableActions, "check") {
+			kind = "call"
+		}
+		if err := r.action(Action{PlayerID: "2", Action: kind}); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+	}
+	if !r.players[0].SittingOut || r.players[0].timeouts < 3 {
+		t.Fatalf("player 1 sitting out %v after %d timeouts", r.players[0].SittingOut, r.players[0].timeouts)
+	}
+	sat := received(c, EventSitOut)
+	if len(sat) != 1 || sat[0].PlayerID != "1" || sat[0].Reason != "timeouts" {
+		t.Fatalf("sit_out events = %+v", sat)
+	}
+}
+
+func TestActingResetsTimeouts(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3")
+	r.startNextHandIfReady() // seat 1 to act
+
+	r.players[0].timeouts = 1
+	if err := r.action(Action{PlayerID: "1", Action: "call"}); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if r.players[0].timeouts != 0 {
+		t.Fatalf("timeouts = %d after acting, want 0", r.players[0].timeouts)
+	}
+}
+
+func TestRemoveIdleSittingOutPlayers(t *testing.T) {
+	r := seatedRoom(t, "1", "2")
+	r.cfg.MaxSitOut = 60
+	if err := r.join(newPlayer("3", "p3", 50*Chip)); err != nil {
+		t.Fatal(err)
+	}
+	c := listen(r)
+
+	// just joined and sitting out: stays for now
+	r.removeIdle()
+	if FindPlayerIndexInRoom(r, "3") < 0 {
+		t.Fatalf("player 3 removed too early")
+	}
+
+	long := time.Now().Add(-61 * time.Second)
+	r.players[FindPlayerIndexInRoom(r, "3")].sitOutSince = long
+	r.players[FindPlayerIndexInRoom(r, "1")].sitOutSince = long // sitting in, not idle
+	r.removeIdle()
+	if FindPlayerIndexInRoom(r, "3") >= 0 || len(r.players) != 2 {
+		t.Fatalf("roster after removing idle players: %+v", r.players)
+	}
+	left := received(c, EventPlayerLeft)
+	if len(left) != 1 || left[0].PlayerID != "3" || left[0].Amount != 50*Chip || left[0].Reason != "idle" {
+		t.Fatalf("player_left events = %+v", left)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+/* === room registry and lobby === */
+
+func newServer() *Server {
+	return &Server{
+		rooms:    make(map[int]*Room),
+		nextID:   1,
+		auth:     newAuth(),
+		bank:     newBank(),
+		adminKey: os.Getenv("POKER_ADMIN_KEY"),
+	}
+}
+
+// addRoom registers a new room and starts its goroutine. ids are never reused,
+// so tokens for a closed room can't work at a new one
+func (s *Server) addRoom(cfg RoomConfig) *Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rm := newRoom(s.nextID, cfg)
+	rm.bank = s.bank
+	rm.store = s.store
+	s.rooms[rm.id] = rm
+	s.nextID++
+	rm.save()
+	s.save()
+	go rm.run()
+	return rm
+}
+
+// nil if there is no such room
+func (s *Server) getRoom(id int) *Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rooms[id]
+}
+
+// the room from ?room=N, writing a 400 or 404 and returning false if there isn't one
+func (s *Server) roomFromRequest(w http.ResponseWriter, r *http.Request) (*Room, bool) {
+	roomID, err := room_request_to_int(r.URL.Query().Get("room"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	rm := s.getRoom(roomID)
+	if rm == nil {
+		http.Error(w, fmt.Sprintf("no room %d", roomID), http.StatusNotFound)
+		return nil, false
+	}
+	return rm, true
+}
+
+// a room as listed in the lobby
+type RoomInfo struct {
+	ID      int        `json:"id"`
+	Config  RoomConfig `json:"config"`
+	Players int        `json:"players"`
+	Seats   int        `json:"seats"`
+	Playing bool       `json:"playing"` // a hand is running
+}
+
+func roomInfo(rm *Room) (RoomInfo, error) {
+	resp := rm.send(Command{Kind: "players"})
+	if resp.Err != nil {
+		return RoomInfo{}, resp.Err
+	}
+	return RoomInfo{
+		ID:      rm.id,
+		Config:  rm.cfg,
+		Players: len(resp.State.Players),
+		Seats:   resp.State.Seats,
+		Playing: resp.State.Hand != nil,
+	}, nil
+}
+
+/*
+create a table
+
+	curl -X POST "http://localhost:8080/rooms" \
+	  -H "Authorization: Bearer $POKER_ADMIN_KEY" \
+	  -H "Content-Type: application/json" \
+	  -d '{"minStack":30,"maxStack":100,"smallBlind":1,"bigBlind":2,"ante":0,"seats":6,"actionTimeout":20}'
+
+	ante, game ("holdem", "omaha" or "shortdeck"), betting ("no-limit", "pot-limit" or "fixed-limit") and actionTimeout are optional, responds 201 with the new room.
+	needs the admin key, like grants
+*/
+func (s *Server) createRoomHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(w, r) {
+		return
+	}
+	var cfg RoomConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, "bad json (need minStack, maxStack, smallBlind, bigBlind, seats)", http.StatusBadRequest)
+		return
+	}
+	if err := cfg.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rm := s.addRoom(cfg)
+	info, err := roomInfo(rm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// GET /rooms -> [{ id, config, players, seats, playing }, ...] ordered by id
+func (s *Server) listRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	rooms := make([]*Room, 0, len(s.rooms))
+	for id := 1; id < s.nextID; id++ {
+		if rm, ok := s.rooms[id]; ok {
+			rooms = append(rooms, rm)
+		}
+	}
+	s.mu.Unlock()
+
+	// ask the rooms without holding the lock, one closing meanwhile is just skipped
+	list := []RoomInfo{}
+	for _, rm := range rooms {
+		if info, err := roomInfo(rm); err == nil {
+			list = append(list, info)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// DELETE /rooms/{id}, only for a table nobody is sitting at. needs the admin key
+func (s *Server) deleteRoomHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(w, r) {
+		return
+	}
+	roomID, err := room_request_to_int(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rm := s.getRoom(roomID)
+	if rm == nil {
+		http.Error(w, fmt.Sprintf("no room %d", roomID), http.StatusNotFound)
+		return
+	}
+	if resp := rm.send(Command{Kind: "close"}); resp.Err != nil {
+		status := http.StatusConflict
+		if errors.Is(resp.Err, errRoomClosed) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, resp.Err.Error(), status)
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.rooms, roomID)
+	if s.store != nil {
+		if err := s.store.remove(roomFile(roomID)); err != nil {
+			fmt.Printf("can't remove saved room %d: %v\n", roomID, err)
+		}
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("closed\n"))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func listRooms(t *testing.T, base string) []RoomInfo {
+	t.Helper()
+	resp, err := http.Get(base + "/rooms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var list []RoomInfo
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode rooms: %v", err)
+	}
+	return list
+}
+
+func TestCreateListAndCloseRooms(t *testing.T) {
+	s := newServer()
+	s.adminKey = "admin"
+	s.addRoom(RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	srv := httptest.NewServer(withCORS(s.routes()))
+	defer srv.Close()
+
+	// opening tables takes the admin key
+	room := `{"minStack":20,"maxStack":200,"smallBlind":1,"bigBlind":2,"seats":4,"actionTimeout":15}`
+	for _, key := range []string{"", "wrong"} {
+		if code := postHTTP(t, srv.URL+"/rooms", key, room); code != http.StatusUnauthorized {
+			t.Fatalf("create with key %q: %d, want 401", key, code)
+		}
+	}
+	s.adminKey = ""
+	if code := postHTTP(t, srv.URL+"/rooms", "", room); code != http.StatusForbidden {
+		t.Fatalf("create with no admin key set: %d, want 403", code)
+	}
+	s.adminKey = "admin"
+
+	if code := postHTTP(t, srv.URL+"/rooms", "admin", `{"minStack":10,"maxStack":100,"smallBlind":1,"bigBlind":2,"seats":11}`); code != http.StatusBadRequest {
+		t.Fatalf("11 seats: %d, want 400", code)
+	}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/rooms", strings.NewReader(room))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created RoomInfo
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.ID != 2 || created.Seats != 4 || created.Config.ActionTimeout != 15 {
+		t.Fatalf("create: %d %+v", resp.StatusCode, created)
+	}
+
+	// the new room takes players like any other
+	body := `{"id":"7","name":"p7","stack":150}`
+	if code := postHTTP(t, srv.URL+"/join?room=2", account(t, s, "7"), body); code != http.StatusOK {
+		t.Fatalf("join room 2: %d", code)
+	}
+	list := listRooms(t, srv.URL)
+	if len(list) != 2 || list[0].ID != 1 || list[1].Players != 1 || list[0].Players != 0 {
+		t.Fatalf("rooms = %+v", list)
+	}
+
+	// only an empty table can be closed
+	del := func(id, key string) int {
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/rooms/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := del("1", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("closing a room without the admin key: %d, want 401", code)
+	}
+	if code := del("2", "admin"); code != http.StatusConflict {
+		t.Fatalf("closing a room with players: %d, want 409", code)
+	}
+	if code := del("1", "admin"); code != http.StatusOK {
+		t.Fatalf("closing an empty room: %d, want 200", code)
+	}
+	if code := del("1", "admin"); code != http.StatusNotFound {
+		t.Fatalf("closing it again: %d, want 404", code)
+	}
+	if list := listRooms(t, srv.URL); len(list) != 1 || list[0].ID != 2 {
+		t.Fatalf("rooms after close = %+v", list)
+	}
+	if resp, _ := http.Get(srv.URL + "/players?room=1"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("players of a closed room: %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestClosedRoomRejectsCommands(t *testing.T) {
+	r := newRoom(1, RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	go r.run()
+	if resp := r.send(Command{Kind: "close"}); resp.Err != nil {
+		t.Fatalf("close: %v", resp.Err)
+	}
+	if resp := r.send(Command{Kind: "join", Player: newPlayer("1", "p1", 50*Chip)}); resp.Err != errRoomClosed {
+		t.Fatalf("join after close: %v, want %v", resp.Err, errRoomClosed)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 )
 
 /* wrapper for CORS */
@@ -10,8 +11,8 @@ import (
 func withCORS(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -20,18 +21,9 @@ func withCORS(h http.Handler) http.Handler {
 	})
 }
 
-/* === main === */
-
-func main() {
-	// room takes in id, minStack
-	s := &Server{
-		room1: newRoom(1, 30.0, 100.0),
-		room2: newRoom(2, 30.0, 100.0),
-	}
-	// launch goroutines
-	go s.room1.run()
-	go s.room2.run()
+/* === routes === */
 
+func (s *Server) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/join", s.joinHandler)
 	mux.HandleFunc("/leave", s.leaveHandler)
@@ -39,7 +31,44 @@ func main() {
 	mux.HandleFunc("/state", s.stateHandler)
 	mux.HandleFunc("/action", s.setActionHandler)
 	mux.HandleFunc("/sitInOrOut", s.sitInOrOutHandler)
+	mux.HandleFunc("/ws", s.wsHandler)
+	mux.HandleFunc("GET /rooms", s.listRoomsHandler)
+	mux.HandleFunc("POST /rooms", s.createRoomHandler)
+	mux.HandleFunc("DELETE /rooms/{id}", s.deleteRoomHandler)
+	mux.HandleFunc("POST /accounts", s.createAccountHandler)
+	mux.HandleFunc("POST /grants", s.grantHandler)
+	mux.HandleFunc("GET /bankroll", s.bankrollHandler)
+	mux.HandleFunc("GET /history", s.historyHandler)
+	mux.HandleFunc("GET /hands/{id}", s.handHandler)
+	mux.HandleFunc("GET /hands/{id}/replay", s.replayHandler)
+	return mux
+}
+
+/* === main === */
+
+func main() {
+	// rooms, bankrolls and hands are kept in POKER_DATA (./data by default)
+	dir := os.Getenv("POKER_DATA")
+	if dir == "" {
+		dir = "data"
+	}
+	store, err := openStore(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := newServer()
+	if err := s.useStore(store); err != nil {
+		log.Fatal(err)
+	}
+
+	// two tables to start with, more can be opened with POST /rooms
+	if len(s.rooms) == 0 {
+		s.addRoom(RoomConfig{MinStack: 30 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 9})
+		s.addRoom(RoomConfig{MinStack: 30 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Ante: Chip / 2, Seats: 6})
+	}
+
+	mux := s.routes()
 
-	log.Println("Server on :8080 | POST /join  POST /leave  GET /players  (use ?room=2 for room 2)")
+	log.Println("Server on :8080 | POST /join  POST /leave  GET /players  GET /ws  GET|POST /rooms  DELETE /rooms/{id}  POST /accounts  POST /grants  GET /bankroll  GET /history  GET /hands/{id}[/replay]  (use ?room=N)")
 	log.Fatal(http.ListenAndServe(":8080", withCORS(mux)))
 }
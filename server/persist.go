@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+/* === saving and restoring rooms, bankrolls and hands (see store.go) === */
+
+// a seated player as saved, Player keeps some of this unexported
+type savedPlayer struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Stack    Chips   `json:"stack"`
+	Seat     int     `json:"seat"`
+	TimeBank float64 `json:"timeBank"`
+	Session  string  `json:"session"` // so tokens still work after a restart
+}
+
+type savedRoom struct {
+	ID             int           `json:"id"`
+	Config         RoomConfig    `json:"config"`
+	ButtonSeat     int           `json:"buttonSeat"`
+	SmallBlindSeat int           `json:"smallBlindSeat"`
+	BigBlindSeat   int           `json:"bigBlindSeat"`
+	HandCount      int           `json:"handCount"`
+	Players        []savedPlayer `json:"players"`
+}
+
+type savedServer struct {
+	NextRoomID int `json:"nextRoomId"`
+}
+
+// the token signing secret, only saved when it doesn't come from POKER_SECRET
+type savedAuth struct {
+	Secret string `json:"secret"` // hex
+}
+
+func roomFile(id int) string {
+	return fmt.Sprintf("room-%d.json", id)
+}
+
+// save writes the room's config and roster. stacks are only ever the ones
+// between hands, so a hand cut off by a restart is just never played
+func (r *Room) save() {
+	if r.store == nil {
+		return
+	}
+	sr := savedRoom{
+		ID:             r.id,
+		Config:         r.cfg,
+		ButtonSeat:     r.buttonSeat,
+		SmallBlindSeat: r.smallBlindSeat,
+		BigBlindSeat:   r.bigBlindSeat,
+		HandCount:      r.handCount,
+		Players:        []savedPlayer{},
+	}
+	for _, p := range r.players {
+		sr.Players = append(sr.Players, savedPlayer{
+			ID:       p.ID,
+			Name:     p.Name,
+			Stack:    p.Stack,
+			Seat:     p.Seat,
+			TimeBank: p.TimeBank,
+			Session:  p.session,
+		})
+	}
+	if err := r.store.writeJSON(roomFile(r.id), sr); err != nil {
+		fmt.Printf("can't save room %d: %v\n", r.id, err)
+	}
+}
+
+// the room as it was saved, not running yet. everyone comes back sitting out
+// (newPlayer) and sits back in once they have reconnected
+func restoreRoom(sr savedRoom) *Room {
+	r := newRoom(sr.ID, sr.Config)
+	r.buttonSeat, r.smallBlindSeat, r.bigBlindSeat = sr.ButtonSeat, sr.SmallBlindSeat, sr.BigBlindSeat
+	r.handCount = sr.HandCount
+	for _, sp := range sr.Players {
+		p := newPlayer(sp.ID, sp.Name, sp.Stack)
+		p.Seat = sp.Seat
+		p.TimeBank = sp.TimeBank
+		p.session = sp.Session
+		p.sitOutSince = time.Now()
+		r.players = append(r.players, p)
+	}
+	r.sortBySeat()
+	return r
+}
+
+// caller holds s.mu
+func (s *Server) save() {
+	if s.store == nil {
+		return
+	}
+	if err := s.store.writeJSON("server.json", savedServer{NextRoomID: s.nextID}); err != nil {
+		fmt.Printf("can't save server: %v\n", err)
+	}
+}
+
+// useStore loads bankrolls and rooms from the store and keeps saving to it from now on
+func (s *Server) useStore(st *Store) error {
+	bank, err := loadBank(st)
+	if err != nil {
+		return fmt.Errorf("loading bank: %w", err)
+	}
+	auth := s.auth
+	if os.Getenv("POKER_SECRET") == "" {
+		if auth, err = loadAuth(st, s.auth); err != nil {
+			return fmt.Errorf("loading auth: %w", err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = st
+	s.bank = bank
+	s.auth = auth
+
+	var ss savedServer
+	if _, err := st.readJSON("server.json", &ss); err != nil {
+		return fmt.Errorf("loading server: %w", err)
+	}
+	names, err := st.list("room-*.json")
+	if err != nil {
+		return err
+	}
+	saved := []savedRoom{}
+	for _, name := range names {
+		var sr savedRoom
+		if _, err := st.readJSON(name, &sr); err != nil {
+			return fmt.Errorf("loading %s: %w", name, err)
+		}
+		saved = append(saved, sr)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].ID < saved[j].ID })
+
+	for _, sr := range saved {
+		rm := restoreRoom(sr)
+		rm.bank = s.bank
+		rm.store = st
+		s.rooms[rm.id] = rm
+		s.nextID = max(s.nextID, rm.id+1)
+		fmt.Printf("restored room %d with %d players\n", rm.id, len(rm.players))
+		go rm.run()
+	}
+	// ids of closed rooms are never handed out again
+	s.nextID = max(s.nextID, ss.NextRoomID)
+	return nil
+}
+
+// loadAuth signs with the secret saved in the store, the first time there is none
+// a's secret is saved instead. without it every token dies with the process
+func loadAuth(st *Store, a *Auth) (*Auth, error) {
+	var sa savedAuth
+	found, err := st.readJSON("auth.json", &sa)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if err := st.writeJSON("auth.json", savedAuth{Secret: hex.EncodeToString(a.secret)}); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+	secret, err := hex.DecodeString(sa.Secret)
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("auth.json has no usable secret")
+	}
+	return &Auth{secret: secret}, nil
+}
+
+// loadBank reads the accounts and rebuilds the balances by replaying the ledger, then keeps appending to both
+func loadBank(st *Store) (*Bank, error) {
+	b := newBank()
+	err := st.readJSONL("accounts.jsonl", func(line []byte) error {
+		var a Account
+		if err := json.Unmarshal(line, &a); err != nil {
+			return err
+		}
+		b.accounts[a.PlayerID] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = st.readJSONL("ledger.jsonl", func(line []byte) error {
+		var e LedgerEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		b.balances[e.PlayerID] = e.Balance
+		b.ledger = append(b.ledger, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.store = st
+	return b, nil
+}
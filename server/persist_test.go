@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreSkipsTornLastLine(t *testing.T) {
+	st, err := openStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err := st.appendJSONL("x.jsonl", map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a crash halfway through the third append
+	f, _ := os.OpenFile(filepath.Join(st.dir, "x.jsonl"), os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"n":3`)
+	f.Close()
+
+	n := 0
+	if err := st.readJSONL("x.jsonl", func([]byte) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("read %d lines, want 2", n)
+	}
+}
+
+func TestRestartRestoresRoomsAndBankrolls(t *testing.T) {
+	dir := t.TempDir()
+	st, _ := openStore(dir)
+	s := newServer()
+	s.adminKey = "admin"
+	if err := s.useStore(st); err != nil {
+		t.Fatal(err)
+	}
+	s.addRoom(RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	s.addRoom(RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	srv := httptest.NewServer(withCORS(s.routes()))
+
+	// close room 2, play one hand in room 1: seat 1 (button) folds to the big blind
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/rooms/2", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("close room 2: %v", err)
+	}
+	t1, t2 := joinHTTP(t, s, srv.URL, "1"), joinHTTP(t, s, srv.URL, "2")
+	postHTTP(t, srv.URL+"/sitInOrOut?room=1&sitIn=true&playerId=1", t1, "")
+	postHTTP(t, srv.URL+"/sitInOrOut?room=1&sitIn=true&playerId=2", t2, "")
+	if code := postHTTP(t, srv.URL+"/action?room=1", t1, `{"playerId":"1","action":"fold"}`); code != http.StatusOK {
+		t.Fatalf("fold: %d", code)
+	}
+	srv.Close()
+
+	// a new server on the same directory, its random secret is replaced by the saved one so old tokens still check out
+	s2 := newServer()
+	st2, _ := openStore(dir)
+	if err := s2.useStore(st2); err != nil {
+		t.Fatal(err)
+	}
+	if len(s2.rooms) != 1 || s2.nextID != 3 {
+		t.Fatalf("restored %d rooms, next id %d, want 1 room and 3", len(s2.rooms), s2.nextID)
+	}
+	rm := s2.getRoom(1)
+	st3 := rm.send(Command{Kind: "players"}).State
+	if len(st3.Players) != 2 || st3.Players[0].Stack != 99*Chip || st3.Players[1].Stack != 101*Chip || st3.ButtonSeat != 1 {
+		t.Fatalf("restored room: %+v", st3)
+	}
+	if err := s2.bank.check("1", account(t, s, "1")); err != nil {
+		t.Fatalf("account 1 after a restart: %v", err)
+	}
+	if bal := s2.bank.balance("1"); bal != testBankroll-100*Chip || len(s2.bank.entries("1")) != 2 {
+		t.Fatalf("player 1 bankroll %s with %d entries", bal, len(s2.bank.entries("1")))
+	}
+
+	srv2 := httptest.NewServer(withCORS(s2.routes()))
+	defer srv2.Close()
+	if !st3.Players[0].SittingOut {
+		t.Fatalf("players should come back sitting out")
+	}
+	if code := postHTTP(t, srv2.URL+"/leave?room=1", t1, `{"id":"1"}`); code != http.StatusOK {
+		t.Fatalf("leave with a token from before the restart: %d", code)
+	}
+	if bal := s2.bank.balance("1"); bal != testBankroll-Chip {
+		t.Fatalf("player 1 cashed out to %s, want %s", bal, testBankroll-Chip)
+	}
+
+	// the hand was recorded, and may be followed by the start of the next one
+	var hands []HandRecord
+	st2.readJSONL("hands.jsonl", func(line []byte) error {
+		var h HandRecord
+		json.Unmarshal(line, &h)
+		hands = append(hands, h)
+		return nil
+	})
+	if len(hands) != 1 || hands[0].ID != "1-1" || len(hands[0].Players) != 2 || hands[0].Players[1].EndStack != 101*Chip {
+		t.Fatalf("hands = %+v", hands)
+	}
+}
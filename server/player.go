@@ -1,24 +1,37 @@
 package main
 
+import "time"
+
 type Player struct {
-	ID            string  `json:"id"`
-	Name          string  `json:"name"`
-	Stack         float64 `json:"stack"`
-	canAct        bool
-	timebank      float64
-	sittingOut    bool
-	hand          []Card
-	pendingAction chan Action
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Stack        Chips  `json:"stack"`
+	Seat         int    `json:"seat"` // 1..number of seats, 0 on join means any free seat
+	canAct       bool
+	TimeBank     float64 `json:"timeBank"` // seconds left once the action clock runs out
+	SittingOut   bool    `json:"sittingOut"`
+	LastResult   Chips   `json:"lastResult"` // chips won (or lost if negative) in the last hand played
+	waitingForBB bool    // sat down or came back, dealt in once the big blind reaches them
+	sitOutNext   bool    // asked to sit out during a hand, happens once it is over
+	sitOutReason string  // why they are sitting out next, for the sit_out event
+	sitOutSince  time.Time
+	timeouts     int    // timed out in a row, reset when they act
+	leaving      bool   // left during a hand, removed once it is over
+	session      string // from the token issued on join
+	folded       bool
+	streetBet    Chips // chips put in on the current street
+	totalBet     Chips // chips put into the pot this hand
+	acted        bool  // acted since the last full raise
+	hand         []Card
 }
 
-func newPlayer(id string, name string, stack float64) Player {
+func newPlayer(id string, name string, stack Chips) Player {
 	return Player{
-		ID:            id,
-		Name:          name,
-		Stack:         stack,
-		sittingOut:    true,
-		canAct:        true,
-		timebank:      60,
-		pendingAction: make(chan Action, 1),
+		ID:         id,
+		Name:       name,
+		Stack:      stack,
+		SittingOut: true,
+		canAct:     true,
+		TimeBank:   defaultTimeBank,
 	}
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// the table as it was after some of a hand's events, rebuilt by the replay
+type ReplayState struct {
+	HandID string    `json:"handId"`
+	Step   int       `json:"step"`            // events applied so far
+	Steps  int       `json:"steps"`           // events in the whole hand
+	Event  *Event    `json:"event,omitempty"` // the event that got us here, nil at step 0
+	Hand   *HandView `json:"hand"`
+	Cards  []Card    `json:"cards,omitempty"` // the viewer's hole cards once they are dealt
+}
+
+// player decisions are what the replay feeds the engine, everything else it publishes itself
+var decisions = []string{"fold", "check", "call", "raise", "allin"}
+
+// replayHand deals the recorded hand again from the recorded seed and feeds the
+// engine the same decisions, keeping the table after every event it publishes.
+// states[0] is before the hand started. the replayed events have to match the
+// recorded ones, if the engine doesn't do the same thing again that is an error.
+// the viewer only sees their own hole cards, everyone's if it is empty
+func replayHand(rec HandRecord, viewer string) ([]ReplayState, error) {
+	players := make([]Player, len(rec.Players))
+	pos := Positions{Dealer: -1, SmallBlind: -1, BigBlind: -1}
+	for i, p := range rec.Players {
+		players[i] = Player{ID: p.ID, Name: p.Name, Seat: p.Seat, Stack: p.StartStack, canAct: true}
+		if p.Seat == rec.SBSeat {
+			pos.SmallBlind = i
+		}
+		if p.Seat == rec.BBSeat {
+			pos.BigBlind = i
+		}
+	}
+	// a dead button deals from the player before it, like the room does
+	if rec.ButtonSeat > 0 && len(players) > 0 {
+		pos.Dealer = indexAtOrBefore(players, rec.ButtonSeat)
+		pos.ButtonSeat = rec.ButtonSeat
+	}
+	if pos.Dealer < 0 || pos.BigBlind < 0 {
+		return nil, fmt.Errorf("hand %s can't be replayed, its positions are missing", rec.ID)
+	}
+	game, err := variantFor(rec.Game, rec.Betting)
+	if err != nil {
+		return nil, fmt.Errorf("hand %s can't be replayed: %w", rec.ID, err)
+	}
+	seed, err := parseSeed(rec.Seed)
+	if err != nil {
+		return nil, fmt.Errorf("hand %s can't be replayed without its seed: %w", rec.ID, err)
+	}
+
+	h := newHand(players, pos, rec.SmallBlind, rec.BigBlind, rec.Ante, seed, game)
+	h.id = rec.ID
+
+	states := []ReplayState{{HandID: rec.ID, Steps: len(rec.Events), Hand: handView(h, time.Time{})}}
+	h.emit = func(e Event) {
+		if e.Type == EventCardsDealt && viewer != "" && e.PlayerID != viewer {
+			e.Cards = nil
+		}
+		st := ReplayState{HandID: rec.ID, Step: len(states), Steps: len(rec.Events), Event: &e, Hand: handView(h, time.Time{})}
+		if i := FindPlayerIndexInHand(h, viewer); i >= 0 && len(h.Players[i].hand) > 0 {
+			st.Cards = append([]Card{}, h.Players[i].hand...)
+		}
+		states = append(states, st)
+	}
+
+	h.start()
+	for _, e := range rec.Events {
+		if e.Type != EventAction || !contains(decisions, e.Action) {
+			continue
+		}
+		if h.currentState == StateOver {
+			return states, fmt.Errorf("replay of %s ended before %s's %s", rec.ID, e.PlayerID, e.Action)
+		}
+		// a fold out of turn is a player who left mid hand
+		if FindPlayerIndexInHand(h, e.PlayerID) != h.actionPlayerIndex && e.Action == "fold" {
+			h.forceFold(e.PlayerID)
+			continue
+		}
+		if err := h.act(Action{PlayerID: e.PlayerID, Action: e.Action, Amount: e.Total}); err != nil {
+			return states, fmt.Errorf("replay of %s: %s's %s: %w", rec.ID, e.PlayerID, e.Action, err)
+		}
+	}
+
+	// same seed and same decisions have to give the same hand
+	for i := range max(len(h.log), len(rec.Events)) {
+		if i >= len(h.log) || i >= len(rec.Events) {
+			return states, fmt.Errorf("replay of %s has %d events, recorded %d", rec.ID, len(h.log), len(rec.Events))
+		}
+		got, _ := json.Marshal(h.log[i])
+		want, _ := json.Marshal(rec.Events[i])
+		if string(got) != string(want) {
+			return states, fmt.Errorf("replay of %s diverged at event %d: recorded %s, replayed %s", rec.ID, i+1, want, got)
+		}
+	}
+	return states, nil
+}
+
+// forViewer is the record as one of its players may see it: their own hole
+// cards and whatever was shown at showdown, no seed or deck (either one gives
+// away every card). events keep their place
+// so they line up with replay steps
+func (rec HandRecord) forViewer(viewer string) HandRecord {
+	shown := map[string]bool{viewer: true}
+	events := []Event{}
+	for _, e := range rec.Events {
+		if e.Type == EventShowdown {
+			shown[e.PlayerID] = true
+		}
+		if e.Type == EventCardsDealt && e.PlayerID != viewer {
+			e.Cards = nil
+		}
+		events = append(events, e)
+	}
+	rec.Events = events
+	rec.Seed = ""
+	players := []PlayerRecord{}
+	for _, p := range rec.Players {
+		if !shown[p.ID] {
+			p.Cards = nil
+		}
+		players = append(players, p)
+	}
+	rec.Players = players
+	return rec
+}
+
+// handForViewer loads /hands/{id} for ?playerId= with their token. only players
+// dealt into the hand can look at it. writes the error and returns false if not
+func (s *Server) handForViewer(w http.ResponseWriter, r *http.Request) (HandRecord, string, bool) {
+	id, viewer := r.PathValue("id"), r.URL.Query().Get("playerId")
+	if s.store == nil {
+		http.Error(w, "hands are not being recorded", http.StatusNotFound)
+		return HandRecord{}, "", false
+	}
+	hands, err := s.store.readHands(func(rec HandRecord) bool { return rec.ID == id })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return HandRecord{}, "", false
+	}
+	if len(hands) == 0 {
+		http.Error(w, fmt.Sprintf("no hand %s", id), http.StatusNotFound)
+		return HandRecord{}, "", false
+	}
+	rec := hands[0]
+	if _, err := s.auth.verify(bearer(r), rec.Room, viewer); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return HandRecord{}, "", false
+	}
+	if _, ok := rec.player(viewer); !ok {
+		http.Error(w, "you were not dealt into this hand", http.StatusForbidden)
+		return HandRecord{}, "", false
+	}
+	return rec, viewer, true
+}
+
+// GET /hands/1-7?playerId=2 -> the hand's record and event log
+func (s *Server) handHandler(w http.ResponseWriter, r *http.Request) {
+	rec, viewer, ok := s.handForViewer(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rec.forViewer(viewer))
+}
+
+// GET /hands/1-7/replay?playerId=2&step=5 -> the table after the 5th event (the end of the hand without step)
+func (s *Server) replayHandler(w http.ResponseWriter, r *http.Request) {
+	rec, viewer, ok := s.handForViewer(w, r)
+	if !ok {
+		return
+	}
+	states, err := replayHand(rec, viewer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	step := len(states) - 1
+	if q := r.URL.Query().Get("step"); q != "" {
+		n, err := strconv.Atoi(q)
+		if err != nil || n < 0 || n >= len(states) {
+			http.Error(w, fmt.Sprintf("step must be between 0 and %d", len(states)-1), http.StatusBadRequest)
+			return
+		}
+		step = n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(states[step])
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplayMatchesRecordedHand(t *testing.T) {
+	rec := playRecordedHand(t)
+	states, err := replayHand(rec, "")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(states) != len(rec.Events)+1 || states[0].Event != nil || len(states[0].Hand.Board) != 0 {
+		t.Fatalf("%d states for %d events", len(states), len(rec.Events))
+	}
+
+	// the state right after the flop is dealt has three cards and the pre-flop pot
+	for _, st := range states {
+		if st.Event != nil && st.Event.Type == EventStreet && st.Event.Street == "flop" {
+			if len(st.Hand.Board) != 3 || st.Hand.Pot != 13*Chip {
+				t.Fatalf("after the flop: board %v pot %s", st.Hand.Board, st.Hand.Pot)
+			}
+		}
+	}
+	// and at the end everyone has the stack they left the hand with
+	last := states[len(states)-1]
+	for i, seat := range last.Hand.Seats {
+		if seat.Stack != rec.Players[i].EndStack {
+			t.Fatalf("seat %d stack %s, recorded %s", seat.Seat, seat.Stack, rec.Players[i].EndStack)
+		}
+	}
+}
+
+func TestReplayDetectsDivergence(t *testing.T) {
+	rec := playRecordedHand(t)
+	// pretend the engine once let seat 1 raise to 8 instead of 6
+	for i, e := range rec.Events {
+		if e.Type == EventAction && e.Action == "raise" {
+			rec.Events[i].Total = 8 * Chip
+		}
+	}
+	if _, err := replayHand(rec, ""); err == nil || !strings.Contains(err.Error(), "diverged") {
+		t.Fatalf("replay of a tampered hand: %v, want a divergence", err)
+	}
+}
+
+func TestReplayNeedsSeed(t *testing.T) {
+	rec := playRecordedHand(t)
+	rec.Seed = ""
+	if _, err := replayHand(rec, ""); err == nil || !strings.Contains(err.Error(), "seed") {
+		t.Fatalf("replay without a seed: %v", err)
+	}
+}
+
+func TestHandEndpoints(t *testing.T) {
+	st, _ := openStore(t.TempDir())
+	s := newServer()
+	if err := s.useStore(st); err != nil {
+		t.Fatal(err)
+	}
+	s.addRoom(RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	srv := httptest.NewServer(withCORS(s.routes()))
+	defer srv.Close()
+
+	t1, t2 := joinHTTP(t, s, srv.URL, "1"), joinHTTP(t, s, srv.URL, "2")
+	postHTTP(t, srv.URL+"/sitInOrOut?room=1&sitIn=true&playerId=1", t1, "")
+	postHTTP(t, srv.URL+"/sitInOrOut?room=1&sitIn=true&playerId=2", t2, "")
+	if code := postHTTP(t, srv.URL+"/action?room=1", t1, `{"playerId":"1","action":"fold"}`); code != http.StatusOK {
+		t.Fatalf("fold: %d", code)
+	}
+
+	get := func(path, token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := get("/hands/1-1?playerId=2", t1); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("someone else's token: %d, want 401", resp.StatusCode)
+	}
+	if resp := get("/hands/9-9?playerId=2", t2); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown hand: %d, want 404", resp.StatusCode)
+	}
+
+	resp := get("/hands/1-1?playerId=2", t2)
+	var rec HandRecord
+	json.NewDecoder(resp.Body).Decode(&rec)
+	resp.Body.Close()
+	if rec.ID != "1-1" || len(rec.Events) == 0 || rec.Seed != "" {
+		t.Fatalf("hand = %+v", rec)
+	}
+	for _, e := range rec.Events {
+		if e.Type == EventCardsDealt && e.PlayerID != "2" && e.Cards != nil {
+			t.Fatalf("player 2 can see player %s's cards", e.PlayerID)
+		}
+	}
+	if p, _ := rec.player("1"); p.Cards != nil {
+		t.Fatalf("player 1 never showed, cards %v", p.Cards)
+	}
+
+	resp = get("/hands/1-1/replay?playerId=2&step=1", t2)
+	var rs ReplayState
+	json.NewDecoder(resp.Body).Decode(&rs)
+	resp.Body.Close()
+	if rs.Step != 1 || rs.Event == nil || rs.Event.Type != EventHandStarted || rs.Steps != len(rec.Events) {
+		t.Fatalf("replay step 1 = %+v", rs)
+	}
+	if resp := get("/hands/1-1/replay?playerId=2&step=999", t2); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("step past the end: %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+)
+
+// Seed decides a whole shuffle: the same seed always gives the same deck.
+// every hand keeps its seed and the hand history records it, so a hand can be dealt again
+type Seed [32]byte
+
+func (s Seed) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func parseSeed(s string) (Seed, error) {
+	var seed Seed
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(seed) {
+		return seed, fmt.Errorf("bad seed %q", s)
+	}
+	copy(seed[:], b)
+	return seed, nil
+}
+
+// randomSeed is where live tables get their seeds, straight from crypto/rand
+func randomSeed() Seed {
+	var seed Seed
+	if _, err := crand.Read(seed[:]); err != nil {
+		panic(err)
+	}
+	return seed
+}
+
+// shuffleDeck is a Fisher-Yates shuffle driven by a ChaCha8 stream keyed with the seed
+func shuffleDeck(deck []Card, seed Seed) {
+	r := rand.New(rand.NewChaCha8(seed))
+	for i := len(deck) - 1; i > 0; i-- {
+		j := r.IntN(i + 1)
+		deck[i], deck[j] = deck[j], deck[i]
+	}
+}
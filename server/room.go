@@ -1,37 +1,169 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
 
+// table settings, fixed when the room is created
+type RoomConfig struct {
+	MinStack       Chips   `json:"minStack"`
+	MaxStack       Chips   `json:"maxStack"`
+	SmallBlind     Chips   `json:"smallBlind"`
+	BigBlind       Chips   `json:"bigBlind"`
+	Ante           Chips   `json:"ante"`           // 0 for no ante
+	Seats          int     `json:"seats"`          // 2 to 10
+	Game           string  `json:"game"`           // "holdem" (the default), "omaha" or "shortdeck", see variant.go
+	Betting        string  `json:"betting"`        // "no-limit", "pot-limit" or "fixed-limit", empty for the game's usual one
+	ActionTimeout  float64 `json:"actionTimeout"`  // seconds to act, 0 for the default
+	TimeBank       float64 `json:"timeBank"`       // seconds of time bank to start with and at most, 0 for the default
+	TimeBankRefill float64 `json:"timeBankRefill"` // seconds added back after each hand, 0 for the default
+	MaxTimeouts    int     `json:"maxTimeouts"`    // timeouts in a row before a player is sat out, 0 for the default
+	MaxSitOut      float64 `json:"maxSitOut"`      // seconds sitting out before a player is removed, 0 for the default
+}
+
+// how long a player gets to act before they are checked or folded, unless the room says otherwise
+const defaultActionTimeout = 30 * time.Second
+
+func (c RoomConfig) actionTimeout() time.Duration {
+	if c.ActionTimeout <= 0 {
+		return defaultActionTimeout
+	}
+	return time.Duration(c.ActionTimeout * float64(time.Second))
+}
+
+// the room's game, validate has already turned away names we don't know
+func (c RoomConfig) variant() Variant {
+	v, _ := variantFor(c.Game, c.Betting)
+	return v
+}
+
+// checks settings sent by clients creating a room
+func (c RoomConfig) validate() error {
+	if _, err := variantFor(c.Game, c.Betting); err != nil {
+		return err
+	}
+	switch {
+	case c.SmallBlind <= 0 || c.BigBlind < c.SmallBlind:
+		return fmt.Errorf("blinds must be positive and the big blind at least the small blind")
+	case c.Ante < 0:
+		return fmt.Errorf("ante can't be negative")
+	case c.MinStack < c.BigBlind || c.MaxStack < c.MinStack:
+		return fmt.Errorf("min buy-in must be at least the big blind and max buy-in at least the min")
+	case c.Seats < 2 || c.Seats > 10:
+		return fmt.Errorf("seats must be between 2 and 10")
+	case c.ActionTimeout != 0 && (c.ActionTimeout < 5 || c.ActionTimeout > 300):
+		return fmt.Errorf("action timeout must be between 5 and 300 seconds")
+	case c.TimeBank < 0 || c.TimeBank > 600:
+		return fmt.Errorf("time bank must be between 0 and 600 seconds")
+	case c.TimeBankRefill < 0 || c.TimeBankRefill > c.timeBank():
+		return fmt.Errorf("time bank refill can't be negative or more than the time bank")
+	case c.MaxTimeouts < 0:
+		return fmt.Errorf("max timeouts can't be negative")
+	case c.MaxSitOut != 0 && c.MaxSitOut < 60:
+		return fmt.Errorf("max sit out must be at least 60 seconds")
+	}
+	return nil
+}
+
+// returned for commands sent to a room that has been closed
+var errRoomClosed = errors.New("room is closed")
+
+// commands are how the http handlers talk to the room goroutine, which owns
+// the roster and the current hand. every command gets exactly one Response
 type Command struct {
-	Kind   string // "join, leave, sit_out"
-	Player Player
+	Kind    string // "join", "leave", "sit", "action", "players", "state", "subscribe", "unsubscribe", "close"
+	Player  Player
+	Action  Action
+	SitIn   bool
+	Session string // from the player's token, checked against the one they joined with
+	Client  *wsClient
+	Reply   chan Response
+}
+
+type Response struct {
+	Err   error
+	State RoomState // copy of the room for "players" and "state" (private view for Player.ID)
+}
+
+// a copy of the room safe to hand to other goroutines
+type RoomState struct {
+	Room              int          `json:"room"`
+	ActionPlayerIndex int          `json:"actionPlayerIndex"`
+	ActionSeat        int          `json:"actionSeat"`
+	ButtonSeat        int          `json:"buttonSeat"`
+	Seats             int          `json:"seats"`
+	Players           []Player     `json:"players"`
+	Hand              *HandView    `json:"hand,omitempty"`   // nil between hands
+	You               *PrivateView `json:"you,omitempty"`    // only for a player dealt into the hand
+	Frozen            *Violation   `json:"frozen,omitempty"` // why the table stopped, nil while it is running
 }
 
 type Room struct {
-	id                 int
-	joinAndLeaveChan   chan Command
-	players            []Player
-	minStack           float64
-	maxStack           float64
-	smallBlindPosition int
-	currentHand        *Hand
-	previousHand       *Hand
-	handDone           chan struct{}
+	id              int
+	commands        chan Command
+	players         []Player
+	cfg             RoomConfig
+	buttonSeat      int // seats from the last hand, -1 before the first one
+	smallBlindSeat  int
+	bigBlindSeat    int
+	currentHand     *Hand
+	previousHand    *Hand
+	clock           *time.Timer // runs while a player is acting, see clock.go
+	clockDeadline   time.Time   // when the clock goes off
+	clockPlayer     string      // who it is running for
+	clockTurn       int         // the hand's turns when it started, see handChanged
+	inTimeBank      bool        // base clock ran out, now using their time bank
+	timeBankStarted time.Time
+	clients         map[*wsClient]bool
+	changed         bool          // events went out since clients last got a snapshot
+	bank            *Bank         // buy ins and cash outs go through it, nil to play without bankrolls
+	store           *Store        // where the roster and finished hands are saved, nil to keep nothing
+	handCount       int           // hands dealt so far
+	seeds           func() Seed   // a seed for each new deck, randomSeed unless a test wants known cards
+	auditSeen       int           // events of the current hand already audited
+	auditDue        string        // who the audit expects to act next
+	frozen          *Violation    // set once an audit fails, see audit.go
+	done            chan struct{} // closed when the room shuts down
 }
 
 // has a command buffer of 16 commands
-func newRoom(id int, minStack float64, maxStack float64) *Room {
+func newRoom(id int, cfg RoomConfig) *Room {
 	return &Room{
-		id:                 id,
-		joinAndLeaveChan:   make(chan Command, 16),
-		players:            make([]Player, 0),
-		minStack:           minStack,
-		maxStack:           maxStack,
-		smallBlindPosition: 0,
-		handDone:           make(chan struct{}, 1),
+		id:             id,
+		commands:       make(chan Command, 16),
+		players:        make([]Player, 0),
+		cfg:            cfg,
+		buttonSeat:     -1,
+		smallBlindSeat: -1,
+		bigBlindSeat:   -1,
+		clients:        make(map[*wsClient]bool),
+		done:           make(chan struct{}),
+		seeds:          randomSeed,
+	}
+}
+
+// send a command to the room goroutine and wait for it to be handled.
+// once the room is closed every command gets errRoomClosed
+func (r *Room) send(cmd Command) Response {
+	cmd.Reply = make(chan Response, 1)
+	select {
+	case r.commands <- cmd:
+	case <-r.done:
+		return Response{Err: errRoomClosed}
+	}
+	select {
+	case resp := <-cmd.Reply:
+		return resp
+	case <-r.done:
+		// it may have been answered just before the room closed
+		select {
+		case resp := <-cmd.Reply:
+			return resp
+		default:
+			return Response{Err: errRoomClosed}
+		}
 	}
 }
 
@@ -53,108 +185,388 @@ func FindPlayerIndexInRoom(r *Room, id string) int {
 	return -1
 }
 
-// assumes: type Room struct { currentHand *Hand; previousHand *Hand; players []Player; smallBlindPosition int }
+// reconcile is the only place the result of a hand reaches the roster.
+// the hand plays with its own copies of the players, once it is over their stacks
+// are copied back, busted players are sat out and anyone who left or asked to sit
+// out during the hand is dealt with
+func (r *Room) reconcile(h *Hand) {
+	for _, hp := range h.Players {
+		i := FindPlayerIndexInRoom(r, hp.ID)
+		if i < 0 {
+			fmt.Printf("player %s finished a hand in room %d but is not in the room\n", hp.ID, r.id)
+			continue
+		}
+		p := &r.players[i]
+		p.LastResult = hp.Stack - p.Stack
+		p.Stack = hp.Stack
+
+		if p.Stack <= 0 || p.sitOutNext {
+			if p.Stack <= 0 {
+				fmt.Printf("player %s is busted, sitting out\n", p.ID)
+			}
+			reason := p.sitOutReason
+			if p.Stack <= 0 {
+				reason = "busted"
+			}
+			p.SittingOut = true
+			p.sitOutSince = time.Now()
+			p.sitOutNext = false
+			p.sitOutReason = ""
+			r.broadcast(Event{Type: EventSitOut, PlayerID: p.ID, Seat: p.Seat, Reason: reason})
+		}
+	}
+
+	// players who left during the hand go now that their stack is final
+	kept := r.players[:0]
+	for _, p := range r.players {
+		if p.leaving {
+			fmt.Printf("player %s left room %d with %s\n", p.ID, r.id, p.Stack)
+			r.cashOut(p, "")
+			continue
+		}
+		kept = append(kept, p)
+	}
+	r.players = kept
+	r.printRoster()
+}
 
 func (r *Room) startNextHandIfReady() {
-	// if an old hand exists and is over, archive it
-	if r.currentHand != nil && r.currentHand.currentState == "over" {
-		r.previousHand = r.currentHand
+	// if a hand is still running, or the table is frozen, don't start a new one
+	if r.currentHand != nil || r.frozen != nil {
+		return
+	}
+	// pick who is dealt in and where the button goes, need at least 2 players
+	eligible, pos, ok := r.dealPlayers()
+	if !ok {
+		return
+	}
+	for i := range eligible {
+		// reset per-hand flags
+		eligible[i].canAct = true
+	}
+
+	// create the new hand (newHand returns *Hand) and run it until someone has to act
+	r.handCount++
+	r.currentHand = newHand(eligible, pos, r.cfg.SmallBlind, r.cfg.BigBlind, r.cfg.Ante, r.seeds(), r.cfg.variant())
+	r.currentHand.id = fmt.Sprintf("%d-%d", r.id, r.handCount)
+	r.currentHand.emit = r.broadcast
+	r.auditSeen, r.auditDue = 0, ""
+	r.currentHand.start()
+	r.handChanged()
+}
+
+// handChanged runs after anything moves the current hand on: it restarts the
+// action clock for whoever acts next, or settles the hand once it is over
+func (r *Room) handChanged() {
+	h := r.currentHand
+	if h == nil {
+		r.stopClock()
+		return
+	}
+	// if the engine broke something, stop before anyone gets paid from it
+	if v := r.auditHand(h); v != nil {
+		r.freeze(v)
+		return
+	}
+	if h.currentState == StateOver {
+		r.stopClock()
+		r.recordHand(h)
+		r.reconcile(h)
+		r.refillTimeBanks(h)
+		r.save()
+		r.previousHand = h
 		r.currentHand = nil
+		r.broadcast(Event{Type: EventHandOver})
+		r.startNextHandIfReady()
+		return
 	}
-	// if a hand is still running, don't start a new one
-	if r.currentHand != nil {
+	// someone else folding or leaving doesn't give the acting player their time back
+	if r.clock != nil && r.clockPlayer == h.Players[h.actionPlayerIndex].ID && r.clockTurn == h.turns {
 		return
 	}
-	// collect players who are NOT sitting out
-	eligible := make([]Player, 0, len(r.players))
-	for i := range r.players {
-		if !r.players[i].sittingOut {
-			// reset per-hand flags
-			r.players[i].canAct = true
-			// optional: drain any stale pendingAction
-			select {
-			case <-r.players[i].pendingAction:
-			default:
+	r.stopClock()
+	r.startClock()
+}
+
+// copy of the room for read only requests. with a player id it also has
+// that player's cards and legal actions, never anyone else's
+func (r *Room) snapshot(playerID string) RoomState {
+	st := RoomState{
+		Room:              r.id,
+		ActionPlayerIndex: -1,
+		ActionSeat:        -1,
+		ButtonSeat:        r.buttonSeat,
+		Seats:             r.cfg.Seats,
+		Players:           append([]Player{}, r.players...),
+		Frozen:            r.frozen,
+	}
+	// roster index and seat of whoever is acting, -1 between hands
+	if h := r.currentHand; h != nil {
+		st.ActionPlayerIndex = FindPlayerIndexInRoom(r, h.Players[h.actionPlayerIndex].ID)
+		st.ActionSeat = h.Players[h.actionPlayerIndex].Seat
+		st.Hand = handView(h, r.clockDeadline)
+		r.clockView(&st)
+		if playerID != "" {
+			st.You = privateView(h, playerID)
+		}
+	}
+	return st
+}
+
+func (r *Room) printRoster() {
+	fmt.Println("Players in room", r.id, ":")
+	if len(r.players) == 0 {
+		fmt.Println("(none)")
+	} else {
+		for _, pl := range r.players {
+			fmt.Printf("- seat %d: %s (%s) stack: %s\n", pl.Seat, pl.Name, pl.ID, pl.Stack)
+		}
+	}
+	fmt.Println()
+}
+
+func (r *Room) join(p Player) error {
+	if r.has(p.ID) {
+		return fmt.Errorf("player id already in room")
+	}
+	// check if name is already in that room
+	for _, pl := range r.players {
+		if pl.Name == p.Name {
+			return fmt.Errorf("name already in room")
+		}
+	}
+	if p.Seat == 0 && r.freeSeat() < 0 {
+		return fmt.Errorf("room is full")
+	}
+	if p.Seat != 0 && seatIndex(r.players, p.Seat) >= 0 {
+		return fmt.Errorf("seat is taken")
+	}
+	// no seat asked for: take the next free one
+	if p.Seat == 0 {
+		p.Seat = r.freeSeat()
+	}
+	p.TimeBank = r.cfg.timeBank()
+	p.sitOutSince = time.Now()
+	// everything else checks out, the buy in comes out of their bankroll
+	if r.bank != nil {
+		if err := r.bank.buyIn(p.ID, r.id, p.Stack); err != nil {
+			return err
+		}
+	}
+	r.players = append(r.players, p)
+	r.sortBySeat()
+	r.broadcast(Event{Type: EventPlayerJoined, PlayerID: p.ID, Seat: p.Seat, Amount: p.Stack})
+	return nil
+}
+
+// a player in the current hand is folded and stays on the roster until the
+// hand is reconciled, so the chips they still have leave with them
+func (r *Room) leave(id string) error {
+	i := FindPlayerIndexInRoom(r, id)
+	if i < 0 || r.players[i].leaving {
+		return fmt.Errorf("player not in room")
+	}
+	if h := r.currentHand; h != nil && FindPlayerIndexInHand(h, id) >= 0 {
+		r.players[i].leaving = true
+		h.forceFold(id)
+		r.handChanged()
+		return nil
+	}
+	r.cashOut(r.players[i], "")
+	r.players = append(r.players[:i], r.players[i+1:]...)
+	return nil
+}
+
+// cashOut is for every player leaving the roster: their stack goes back to
+// their bankroll and the table is told. the caller takes them off the roster
+func (r *Room) cashOut(p Player, reason string) {
+	if r.bank != nil && p.Stack > 0 {
+		// they are gone from the table either way, the log is all that's left to put the chips back by hand
+		if err := r.bank.cashOut(p.ID, r.id, p.Stack); err != nil {
+			fmt.Printf("room %d: cash out of %s for %s lost: %v\n", r.id, p.ID, p.Stack, err)
+		}
+	}
+	r.broadcast(Event{Type: EventPlayerLeft, PlayerID: p.ID, Seat: p.Seat, Amount: p.Stack, Reason: reason})
+}
+
+// players in a hand can ask to sit out, it happens when the hand is over
+func (r *Room) sit(id string, sitIn bool) error {
+	i := FindPlayerIndexInRoom(r, id)
+	if i < 0 {
+		return fmt.Errorf("player not in room")
+	}
+	p := &r.players[i]
+	if r.currentHand != nil && FindPlayerIndexInHand(r.currentHand, id) >= 0 {
+		if sitIn == p.sitOutNext {
+			p.sitOutNext = !sitIn
+			if sitIn {
+				p.timeouts = 0
 			}
-			eligible = append(eligible, r.players[i])
+			return nil
 		}
+		return fmt.Errorf("already in that state")
 	}
-	// need at least 2 players to start a hand
-	if len(eligible) < 2 {
-		return
+	if sitIn && p.SittingOut && p.Stack <= 0 {
+		// nothing to play with, the seat only goes back to them through a new buy-in
+		return fmt.Errorf("no chips left, leave and join again to buy in")
 	}
-	// ensure smallBlindPosition is in range of eligible players
-	if r.smallBlindPosition < 0 {
-		r.smallBlindPosition = 0
+	if sitIn && p.SittingOut {
+		// missed blinds while away, wait for the big blind to come around
+		p.SittingOut = false
+		p.waitingForBB = true
+		p.timeouts = 0
+		r.broadcast(Event{Type: EventSitIn, PlayerID: p.ID, Seat: p.Seat})
+	} else if !sitIn && !p.SittingOut {
+		p.SittingOut = true
+		p.sitOutSince = time.Now()
+		r.broadcast(Event{Type: EventSitOut, PlayerID: p.ID, Seat: p.Seat})
+	} else {
+		return fmt.Errorf("already in that state")
 	}
-	r.smallBlindPosition %= len(eligible)
+	return nil
+}
 
-	// create the new hand (newHand returns *Hand)
-	r.currentHand = newHand(eligible, r.smallBlindPosition)
-	// advance blinds for the NEXT hand
-	r.smallBlindPosition = (r.smallBlindPosition + 1) % len(eligible)
+func (r *Room) action(a Action) error {
+	h := r.currentHand
+	if h == nil {
+		return fmt.Errorf("no active hand")
+	}
+	if FindPlayerIndexInHand(h, a.PlayerID) < 0 {
+		return fmt.Errorf("unknown player")
+	}
+	if err := h.act(a); err != nil {
+		return err
+	}
+	// acting themselves, so they are not away
+	if i := FindPlayerIndexInRoom(r, a.PlayerID); i >= 0 {
+		r.players[i].timeouts = 0
+	}
+	r.handChanged()
+	return nil
+}
 
-	// run the hand as a go routine
-	go func(h *Hand) {
-		h.run()
-		h.currentState = "over"
-		// notify the room that this hand finished (dont need a value just anything)
-		select {
-		case r.handDone <- struct{}{}:
-		default:
+// the session from a player's token has to be the one they joined with, so a token
+// from an earlier seat (or from someone who joined with the same id before) is no good
+func (r *Room) checkSession(id, session string) error {
+	i := FindPlayerIndexInRoom(r, id)
+	if i < 0 {
+		return fmt.Errorf("player not in room")
+	}
+	if r.players[i].session != session {
+		return errBadSession
+	}
+	return nil
+}
+
+func (r *Room) handleCommand(cmd Command) Response {
+	// anything done as a player, or showing their cards, needs their session
+	id := cmd.Player.ID
+	if cmd.Kind == "action" {
+		id = cmd.Action.PlayerID
+	}
+	if cmd.Client != nil {
+		id = cmd.Client.playerID
+	}
+	switch cmd.Kind {
+	case "leave", "sit", "action", "state", "subscribe":
+		if id != "" {
+			if err := r.checkSession(id, cmd.Session); err != nil {
+				return Response{Err: err}
+			}
+		}
+	}
+
+	// a frozen table can still be looked at, nothing else
+	switch cmd.Kind {
+	case "join", "leave", "sit", "action":
+		if r.frozen != nil {
+			return Response{Err: errTableFrozen}
 		}
-	}(r.currentHand)
+	}
+
+	var err error
+	switch cmd.Kind {
+	case "join":
+		err = r.join(cmd.Player)
+	case "leave":
+		err = r.leave(cmd.Player.ID)
+	case "sit":
+		err = r.sit(cmd.Player.ID, cmd.SitIn)
+	case "action":
+		return Response{Err: r.action(cmd.Action)}
+	case "players":
+		return Response{State: r.snapshot("")}
+	case "state":
+		return Response{State: r.snapshot(cmd.Player.ID)}
+	case "subscribe":
+		r.subscribe(cmd.Client)
+		return Response{}
+	case "unsubscribe":
+		r.unsubscribe(cmd.Client)
+		return Response{}
+	case "close":
+		return Response{Err: r.close()}
+	default:
+		return Response{Err: fmt.Errorf("unknown command %s", cmd.Kind)}
+	}
+	if err != nil {
+		return Response{Err: err}
+	}
+
+	// After any roster change, we might now be eligible to start a hand:
+	r.save()
+	r.printRoster()
+	r.startNextHandIfReady()
+	return Response{}
 }
 
-// function operates on a pointer receiver to actually change the room in memory, r Room would make a copy
+// only an empty table can be closed. websocket clients are disconnected,
+// run stops once the reply is sent
+func (r *Room) close() error {
+	if len(r.players) > 0 {
+		return fmt.Errorf("room still has %d players", len(r.players))
+	}
+	for c := range r.clients {
+		r.unsubscribe(c)
+	}
+	return nil
+}
+
+// function operates on a pointer receiver to actually change the room in memory, r Room would make a copy.
+// this goroutine is the only one that touches the roster and the current hand
 func (r *Room) run() {
 	ticker := time.NewTicker(400 * time.Millisecond) // light heartbeat
 	defer ticker.Stop()
 
 	for {
+		// only wait on the clock while somebody is acting
+		var clock <-chan time.Time
+		if r.clock != nil {
+			clock = r.clock.C
+		}
+
 		select {
-		case cmd := <-r.joinAndLeaveChan:
-			switch cmd.Kind {
-			case "join":
-				if !r.has(cmd.Player.ID) {
-					r.players = append(r.players, cmd.Player)
-				} else {
-					fmt.Printf("Player %s already in room %d\n", cmd.Player.ID, r.id)
-				}
-			case "leave":
-				id := cmd.Player.ID
-				dst := r.players[:0]
-				for _, p := range r.players {
-					if p.ID != id {
-						dst = append(dst, p)
-					}
-				}
-				r.players = dst
-			}
-			// optional: print roster
-			fmt.Println("Players in room", r.id, ":")
-			if len(r.players) == 0 {
-				fmt.Println("(none)")
-			} else {
-				for _, pl := range r.players {
-					fmt.Printf("- %s (%s) stack: %.2f\n", pl.Name, pl.ID, pl.Stack)
-				}
+		case cmd := <-r.commands:
+			resp := r.handleCommand(cmd)
+			cmd.Reply <- resp
+			if cmd.Kind == "close" && resp.Err == nil {
+				close(r.done)
+				return
 			}
-			fmt.Println()
-			// After any roster change, we might now be eligible to start a hand:
-			r.startNextHandIfReady()
 
-		case <-r.handDone:
-			// hand finished; try to start the next one right away
-			if r.currentHand != nil {
-				r.previousHand = r.currentHand
-				r.currentHand = nil
-			}
-			r.startNextHandIfReady()
+		case <-clock:
+			// acting player ran out of time, or of base time and now uses their time bank
+			r.clockExpired()
 
 		case <-ticker.C:
 			// periodic check keeps things moving even without joins/leaves
+			if r.frozen == nil {
+				r.removeIdle()
+			}
 			r.startNextHandIfReady()
 		}
+
+		// one snapshot per client for everything that just happened
+		r.pushState()
 	}
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// many clients hitting one room at once, run with -race to catch shared state
+func TestRoomConcurrentClients(t *testing.T) {
+	r := newRoom(1, RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	go r.run()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprint(i)
+			if resp := r.send(Command{Kind: "join", Player: newPlayer(id, "p"+id, 100*Chip)}); resp.Err != nil {
+				t.Errorf("join %s: %v", id, resp.Err)
+				return
+			}
+			if resp := r.send(Command{Kind: "sit", Player: Player{ID: id}, SitIn: true}); resp.Err != nil {
+				t.Errorf("sit in %s: %v", id, resp.Err)
+			}
+			// everyone also spams the read endpoints
+			for j := 0; j < 50; j++ {
+				r.send(Command{Kind: "state"})
+				r.send(Command{Kind: "players"})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// play a few hands by always calling or checking for whoever is acting
+	for n := 0; n < 200; n++ {
+		st := r.send(Command{Kind: "state"}).State
+		if st.ActionPlayerIndex < 0 {
+			continue
+		}
+		id := st.Players[st.ActionPlayerIndex].ID
+		wg.Add(2)
+		for _, kind := range []string{"call", "check"} {
+			go func(kind string) {
+				defer wg.Done()
+				r.send(Command{Kind: "action", Action: Action{PlayerID: id, Action: kind}})
+			}(kind)
+		}
+		wg.Wait()
+	}
+
+	st := r.send(Command{Kind: "players"}).State
+	if len(st.Players) != 6 {
+		t.Fatalf("got %d players, want 6", len(st.Players))
+	}
+	seats := map[int]bool{}
+	total := Chips(0)
+	for _, p := range st.Players {
+		if seats[p.Seat] {
+			t.Fatalf("two players in seat %d", p.Seat)
+		}
+		seats[p.Seat] = true
+		total += p.Stack
+	}
+	// roster stacks only change when a hand is settled, so no chips can be missing
+	if total != 600*Chip {
+		t.Fatalf("stacks add up to %s, want 600", total)
+	}
+}
+
+// seat players in order and sit them all in, without starting the room goroutine
+func seatedRoom(t *testing.T, ids ...string) *Room {
+	r := newRoom(1, RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	for _, id := range ids {
+		if err := r.join(newPlayer(id, "p"+id, 100*Chip)); err != nil {
+			t.Fatalf("join %s: %v", id, err)
+		}
+	}
+	for _, id := range ids {
+		if err := r.sit(id, true); err != nil {
+			t.Fatalf("sit in %s: %v", id, err)
+		}
+	}
+	return r
+}
+
+func TestLeaveMidHandReconciles(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3")
+	r.startNextHandIfReady() // button seat 1, small blind seat 2, big blind seat 3
+
+	// the small blind leaves mid hand: folded now, removed when the hand is over
+	if err := r.leave("2"); err != nil {
+		t.Fatalf("leave: %v", err)
+	}
+	if FindPlayerIndexInRoom(r, "2") < 0 {
+		t.Fatalf("player left the roster before the hand was over")
+	}
+	// button folds, big blind wins the blinds
+	if err := r.action(Action{PlayerID: "1", Action: "fold"}); err != nil {
+		t.Fatalf("fold: %v", err)
+	}
+
+	if FindPlayerIndexInRoom(r, "2") >= 0 {
+		t.Fatalf("player 2 should be gone after the hand")
+	}
+	bb := r.players[FindPlayerIndexInRoom(r, "3")]
+	if bb.Stack != 101*Chip || bb.LastResult != Chip {
+		t.Fatalf("big blind stack %s result %s, want 101 / +1", bb.Stack, bb.LastResult)
+	}
+}
+
+func TestSitOutDuringHandAppliesAfter(t *testing.T) {
+	r := seatedRoom(t, "1", "2")
+	r.startNextHandIfReady()
+
+	if err := r.sit("1", false); err != nil {
+		t.Fatalf("sit out during hand: %v", err)
+	}
+	if r.players[0].SittingOut {
+		t.Fatalf("sit out should wait for the hand to finish")
+	}
+	// heads up the button (seat 1) acts first
+	if err := r.action(Action{PlayerID: "1", Action: "fold"}); err != nil {
+		t.Fatalf("fold: %v", err)
+	}
+	if !r.players[0].SittingOut || r.currentHand != nil {
+		t.Fatalf("player 1 should be sitting out and no hand running")
+	}
+}
+
+func TestBustedPlayerCantSitIn(t *testing.T) {
+	r := seatedRoom(t, "1", "2")
+	r.seeds = func() Seed { return Seed{7} }
+	r.startNextHandIfReady()
+	for _, a := range []Action{{PlayerID: "1", Action: "allin"}, {PlayerID: "2", Action: "call"}} {
+		if err := r.action(a); err != nil {
+			t.Fatalf("%s %s: %v", a.PlayerID, a.Action, err)
+		}
+	}
+	busted := ""
+	for _, p := range r.players {
+		if p.Stack == 0 {
+			busted = p.ID
+		}
+	}
+	if busted == "" {
+		t.Fatalf("nobody busted: %+v", r.players)
+	}
+	if err := r.sit(busted, true); err == nil {
+		t.Fatalf("busted player %s sat back in with no chips", busted)
+	}
+	if p := r.players[FindPlayerIndexInRoom(r, busted)]; !p.SittingOut {
+		t.Fatalf("busted player is dealt in")
+	}
+}
+
+func TestStateShowsOnlyOwnCards(t *testing.T) {
+	r := seatedRoom(t, "1", "2", "3")
+	r.startNextHandIfReady() // button seat 1, blinds seats 2 and 3, seat 1 to act
+
+	st := r.snapshot("1")
+	if st.Hand == nil || st.You == nil {
+		t.Fatalf("want hand and private view during a hand")
+	}
+	if len(st.You.Cards) != 2 || st.Hand.ActionSeat != 1 || st.Hand.Pot != 3*Chip {
+		t.Fatalf("cards %v action seat %d pot %s", st.You.Cards, st.Hand.ActionSeat, st.Hand.Pot)
+	}
+	if st.You.ToCall != 2*Chip || st.You.MinRaise != 4*Chip || st.You.MaxRaise != 100*Chip {
+		t.Fatalf("to call %s raise %s-%s, want 2 and 4-100", st.You.ToCall, st.You.MinRaise, st.You.MaxRaise)
+	}
+
+	// not their turn: cards but no actions, and nothing about anyone else's cards
+	other := r.snapshot("2")
+	if len(other.You.Actions) != 0 || len(other.You.Cards) != 2 {
+		t.Fatalf("player 2 view = %+v", other.You)
+	}
+	if r.snapshot("").You != nil {
+		t.Fatalf("spectators should not get a private view")
+	}
+	// pre-flop there is no board, so another player's card anywhere in the json is a leak
+	b, _ := json.Marshal(st)
+	for _, c := range r.currentHand.Players[1].hand {
+		if strings.Contains(string(b), fmt.Sprintf(`{"suit":"%s","rank":"%s"}`, c.Suit, c.Rank)) {
+			t.Fatalf("player 1 state leaks player 2 card %v", c)
+		}
+	}
+}
@@ -22,15 +22,15 @@ func TestShuffleDistribution(t *testing.T) {
 		counts := make(map[string]int, positions)
 
 		for i := 0; i < runs; i++ {
-			h := newHand(players, 0)
+			h := newHand(players, Positions{Dealer: 0, SmallBlind: 0, BigBlind: 1}, Chip, 2*Chip, 0, randomSeed(), variants["holdem"])
 			card := h.deck[0] // position 0
 			counts[card.Suit+card.Rank]++
 		}
 
 		var chi float64
 		// loop all 52 categories
-		suits := []string{"Spades", "Hearts", "Diamonds", "Clubs"}
-		ranks := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}
+		suits := []string{"S", "H", "D", "C"}
+		ranks := []string{"14", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}
 		for _, s := range suits {
 			for _, r := range ranks {
 				obs := float64(counts[s+r])
@@ -57,3 +57,22 @@ func TestShuffleDistribution(t *testing.T) {
 		t.Fatalf("mean chi² %.3f deviates > 3*SEM (%.3f) from df=%.0f", mean, 3*sem, df)
 	}
 }
+
+func TestSeedDecidesDeck(t *testing.T) {
+	players := []Player{{Name: "P1"}, {Name: "P2"}}
+	pos := Positions{Dealer: 0, SmallBlind: 0, BigBlind: 1}
+	a := newHand(players, pos, Chip, 2*Chip, 0, Seed{1}, variants["holdem"])
+	b := newHand(players, pos, Chip, 2*Chip, 0, Seed{1}, variants["holdem"])
+	c := newHand(players, pos, Chip, 2*Chip, 0, Seed{2}, variants["holdem"])
+	if fmt.Sprint(a.deck) != fmt.Sprint(b.deck) {
+		t.Fatalf("same seed gave two different decks")
+	}
+	if fmt.Sprint(a.deck) == fmt.Sprint(c.deck) {
+		t.Fatalf("different seeds gave the same deck")
+	}
+
+	seed, err := parseSeed(Seed{1}.String())
+	if err != nil || seed != (Seed{1}) {
+		t.Fatalf("seed didn't survive a round trip: %v", err)
+	}
+}
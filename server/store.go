@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store keeps the server's state in a directory of plain files so a restart
+// doesn't wipe the tables:
+//
+//	server.json     next room id
+//	auth.json       the token signing secret, unless POKER_SECRET is set
+//	room-<id>.json  a room's config, button and seated players
+//	accounts.jsonl  every bankroll account, with a hash of its secret
+//	ledger.jsonl    every bankroll entry, appended as it happens
+//	hands.jsonl     every finished hand, appended as it happens
+//
+// json files are replaced atomically (write a temp file, then rename) and the
+// jsonl files are only ever appended to
+type Store struct {
+	dir string
+	mu  sync.Mutex // appends from different rooms go one at a time
+}
+
+func openStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// writeJSON replaces name with v, readers see the old file or the new one, never half of it
+func (st *Store) writeJSON(name string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(st.dir, name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(st.dir, name))
+}
+
+// readJSON loads name into v, false if there is no such file yet
+func (st *Store) readJSON(name string, v any) (bool, error) {
+	b, err := os.ReadFile(filepath.Join(st.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+func (st *Store) remove(name string) error {
+	err := os.Remove(filepath.Join(st.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// appendJSONL adds v as one line to name
+func (st *Store) appendJSONL(name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(st.dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readJSONL calls fn with every line of name in order. a torn last line
+// (crash in the middle of an append) is skipped
+func (st *Store) readJSONL(name string, fn func(line []byte) error) error {
+	f, err := os.Open(filepath.Join(st.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var pending []byte
+	for sc.Scan() {
+		if pending != nil {
+			if err := fn(pending); err != nil {
+				return err
+			}
+		}
+		pending = append([]byte{}, sc.Bytes()...)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if pending != nil && json.Valid(pending) {
+		return fn(pending)
+	}
+	if pending != nil {
+		fmt.Printf("skipping torn last line of %s\n", name)
+	}
+	return nil
+}
+
+// names of the files in the store matching a glob pattern
+func (st *Store) list(pattern string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(st.dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+	}
+	return names, nil
+}
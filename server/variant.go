@@ -0,0 +1,120 @@
+package main
+
+import "fmt"
+
+// betting structures, how big a bet or raise can be. betting.go does the sums
+const (
+	NoLimit    = "no-limit"    // anything up to the whole stack
+	PotLimit   = "pot-limit"   // at most the size of the pot after calling
+	FixedLimit = "fixed-limit" // exactly one bet, the small bet pre-flop and on the flop, the big bet on the turn and river
+)
+
+// bets a fixed-limit street can have, the big blind is the first one pre-flop
+const limitBetCap = 4
+
+// how each betting structure is written in a hand history title
+var bettingTitles = map[string]string{NoLimit: "No Limit", PotLimit: "Pot Limit", FixedLimit: "Limit"}
+
+// Variant is the game a room deals: how many hole cards each player gets, how
+// many of them have to play, which deck, and the betting structure. rooms pick one by name
+// with RoomConfig.Game (and can change how it is bet with RoomConfig.Betting),
+// the zero Variant plays like no-limit hold'em
+type Variant struct {
+	Name      string // what RoomConfig.Game asks for
+	Title     string // for hand histories, "Hold'em"
+	HoleCards int    // 0 deals two
+	UseHole   int    // exactly this many hole cards go into the best hand, 0 for any number
+	ShortDeck bool   // no 2s to 5s, A-6-7-8-9 is a straight and a flush beats a full house
+	Betting   string // NoLimit, PotLimit or FixedLimit
+}
+
+var variants = map[string]Variant{
+	"holdem":    {Name: "holdem", Title: "Hold'em", HoleCards: 2, Betting: NoLimit},
+	"omaha":     {Name: "omaha", Title: "Omaha", HoleCards: 4, UseHole: 2, Betting: PotLimit},
+	"shortdeck": {Name: "shortdeck", Title: "6+ Hold'em", HoleCards: 2, ShortDeck: true, Betting: NoLimit},
+}
+
+// the game and how it is bet, as PokerStars writes it: "Hold'em No Limit"
+func (v Variant) title() string {
+	return v.Title + " " + bettingTitles[v.Betting]
+}
+
+// hole cards dealt to each player, two when the Variant doesn't say
+func (v Variant) holeCards() int {
+	if v.HoleCards == 0 {
+		return 2
+	}
+	return v.HoleCards
+}
+
+// how the game ranks hands
+func (v Variant) rules() handRules {
+	if v.ShortDeck {
+		return shortDeckRules
+	}
+	return standardRules
+}
+
+// the default game, for rooms and hand records from before there were others
+const defaultGame = "holdem"
+
+// variantFor looks up a game, betting changes its structure unless it is empty
+func variantFor(name string, betting string) (Variant, error) {
+	if name == "" {
+		name = defaultGame
+	}
+	v, ok := variants[name]
+	if !ok {
+		return Variant{}, fmt.Errorf("unknown game %q", name)
+	}
+	if betting != "" {
+		if _, ok := bettingTitles[betting]; !ok {
+			return Variant{}, fmt.Errorf("unknown betting structure %q, want no-limit, pot-limit or fixed-limit", betting)
+		}
+		v.Betting = betting
+	}
+	return v, nil
+}
+
+// bestHand is the best five cards a player can make with their hole cards and the board.
+// omaha has to use exactly two of the four hole cards and three from the board
+func (v Variant) bestHand(hole []Card, board []Card) BestHand {
+	if v.UseHole == 0 || len(board) < 5-v.UseHole {
+		cards := make([]Card, 0, len(hole)+len(board))
+		cards = append(cards, hole...)
+		cards = append(cards, board...)
+		return v.rules().best(cards)
+	}
+
+	var best BestHand
+	found := false
+	for _, h := range combinations(hole, v.UseHole) {
+		for _, b := range combinations(board, 5-v.UseHole) {
+			hand := v.rules().evaluate(append(append([]Card{}, h...), b...))
+			if !found || compareHands(hand, best) > 0 {
+				best = hand
+				found = true
+			}
+		}
+	}
+	return best
+}
+
+// every way of picking k of cards, in order
+func combinations(cards []Card, k int) [][]Card {
+	out := [][]Card{}
+	combo := make([]Card, k)
+	var pick func(start int, depth int)
+	pick = func(start int, depth int) {
+		if depth == k {
+			out = append(out, append([]Card{}, combo...))
+			return
+		}
+		for i := start; i <= len(cards)-(k-depth); i++ {
+			combo[depth] = cards[i]
+			pick(i+1, depth+1)
+		}
+	}
+	pick(0, 0)
+	return out
+}
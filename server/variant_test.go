@@ -0,0 +1,200 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOmahaUsesExactlyTwoHoleCards(t *testing.T) {
+	omaha := variants["omaha"]
+	tests := []struct {
+		hole, board string
+		holdem      HandType
+		omaha       HandType
+	}{
+		// quads on the board only make trips with two hole cards
+		{"14S 13S 5H 4D", "9H 9D 9S 9C 2D", Quads, ThreeOfAKind},
+		// one spade in hand is no flush
+		{"14S 3H 3D 13D", "2S 7S 9S 4S 5C", Flush, Straight},
+		// two are
+		{"14S 3S 3D 13D", "2S 7S 9S 4H 5C", Flush, Flush},
+	}
+	for _, tt := range tests {
+		if got := variants["holdem"].bestHand(cards(tt.hole), cards(tt.board)); got.Type != tt.holdem {
+			t.Errorf("%s on %s in hold'em: %s, want %s", tt.hole, tt.board, got.Type, tt.holdem)
+		}
+		if got := omaha.bestHand(cards(tt.hole), cards(tt.board)); got.Type != tt.omaha {
+			t.Errorf("%s on %s in omaha: %s, want %s", tt.hole, tt.board, got.Type, tt.omaha)
+		}
+	}
+}
+
+func TestPotLimitRaises(t *testing.T) {
+	h := newHand([]Player{
+		{ID: "A", Stack: 100 * Chip},
+		{ID: "B", Stack: 100 * Chip},
+		{ID: "C", Stack: 100 * Chip},
+	}, Positions{Dealer: 0, SmallBlind: 1, BigBlind: 2}, Chip, 2*Chip, 0, Seed{}, variants["omaha"])
+	h.start()
+
+	if len(h.Players[0].hand) != 4 {
+		t.Fatalf("dealt %d hole cards, want 4", len(h.Players[0].hand))
+	}
+	// the button calls 2 into a pot of 3, then can raise by the 5 that makes: to 7
+	if contains(h.avaliableActions, "allin") {
+		t.Fatalf("a 100 chip stack can't shove into a pot of 3")
+	}
+	if err := h.act(Action{PlayerID: "A", Action: "raise", Amount: 8 * Chip}); err == nil || !strings.Contains(err.Error(), "pot limit") {
+		t.Fatalf("raise over the pot: %v", err)
+	}
+	if err := h.act(Action{PlayerID: "A", Action: "raise", Amount: 7 * Chip}); err != nil {
+		t.Fatal(err)
+	}
+	// small blind: 6 to call, pot is then 16, so 7 + 16
+	if v := privateView(h, "B"); v.MinRaise != 12*Chip || v.MaxRaise != 23*Chip {
+		t.Fatalf("small blind can raise %s-%s, want 12-23", v.MinRaise, v.MaxRaise)
+	}
+}
+
+func TestOmahaRoom(t *testing.T) {
+	if err := (RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6, Game: "stud"}).validate(); err == nil {
+		t.Fatalf("a room of a game we don't deal")
+	}
+	if err := (RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6, Betting: "spread-limit"}).validate(); err == nil {
+		t.Fatalf("a room bet in a way we don't know")
+	}
+	if limit, _ := variantFor("holdem", FixedLimit); limit.title() != "Hold'em Limit" {
+		t.Fatalf("fixed-limit hold'em is called %q", limit.title())
+	}
+
+	r := seatedRoom(t, "1", "2", "3")
+	r.cfg.Game = "omaha"
+	st, err := openStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.store = st
+	r.startNextHandIfReady()
+	for _, id := range []string{"1", "2"} {
+		if err := r.action(Action{PlayerID: id, Action: "fold"}); err != nil {
+			t.Fatalf("%s fold: %v", id, err)
+		}
+	}
+	hands, err := st.readHands(func(HandRecord) bool { return true })
+	if err != nil || len(hands) != 1 {
+		t.Fatalf("recorded %d hands (%v)", len(hands), err)
+	}
+	rec := hands[0]
+	if rec.Game != "omaha" || len(rec.Players[0].Cards) != 4 || !strings.HasPrefix(rec.text(""), "PokerStars Hand #1000001: Omaha Pot Limit (1/2)") {
+		t.Fatalf("record game %q cards %v", rec.Game, rec.Players[0].Cards)
+	}
+	if _, err := replayHand(rec, ""); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+}
+
+func TestZeroVariantIsHoldem(t *testing.T) {
+	h := newHand([]Player{
+		{ID: "A", Stack: 100 * Chip},
+		{ID: "B", Stack: 100 * Chip},
+	}, Positions{Dealer: 0, SmallBlind: 0, BigBlind: 1}, Chip, 2*Chip, 0, Seed{}, Variant{})
+	if len(h.deck) != 52 {
+		t.Fatalf("the zero variant has %d cards, want 52", len(h.deck))
+	}
+	h.start()
+	if len(h.Players[0].hand) != 2 || len(h.Players[1].hand) != 2 {
+		t.Fatalf("the zero variant dealt %d and %d hole cards, want 2", len(h.Players[0].hand), len(h.Players[1].hand))
+	}
+	if v := privateView(h, "A"); v.MaxRaise != 100*Chip {
+		t.Fatalf("the zero variant can raise to %s, want all in for 100", v.MaxRaise)
+	}
+}
+
+func TestShortDeckRankings(t *testing.T) {
+	short := variants["shortdeck"]
+	tests := []struct {
+		hole, board string
+		holdem      HandType
+		shortDeck   HandType
+	}{
+		// the ace plays low under the 6
+		{"14S 6H", "7D 8C 9S 13H 13D", Pair, Straight},
+		{"14S 6S", "7S 8S 9S 13H 11D", Flush, StraightFlush},
+	}
+	for _, tt := range tests {
+		if got := variants["holdem"].bestHand(cards(tt.hole), cards(tt.board)); got.Type != tt.holdem {
+			t.Errorf("%s on %s in hold'em: %s, want %s", tt.hole, tt.board, got.Type, tt.holdem)
+		}
+		if got := short.bestHand(cards(tt.hole), cards(tt.board)); got.Type != tt.shortDeck {
+			t.Errorf("%s on %s in short deck: %s, want %s", tt.hole, tt.board, got.Type, tt.shortDeck)
+		}
+	}
+
+	compares := []struct {
+		a, b string
+		want int
+	}{
+		// flush over full house
+		{"14S 11S 9S 7S 6S", "13D 13C 13H 14C 14H", 1},
+		// A-6-7-8-9 is the lowest straight, 9 high
+		{"14D 6S 7H 8C 9D", "6S 7H 8C 9D 10H", -1},
+		{"14D 6S 7H 8C 9D", "13S 13H 13D 12C 11S", 1},
+		{"14D 6S 7H 8C 9D", "14S 6H 7D 8S 9C", 0},
+		// the rest ranks as usual
+		{"9S 9H 9D 9C 6S", "14S 13S 12S 11S 10S", -1},
+		{"13D 13C 13H 14C 14H", "14D 6S 7H 8C 9D", 1},
+	}
+	for _, tt := range compares {
+		a, b := shortDeckRules.evaluate(cards(tt.a)), shortDeckRules.evaluate(cards(tt.b))
+		if got := compareHands(a, b); got != tt.want {
+			t.Errorf("short deck compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	// a flush and a full house on the same board
+	board := cards("13S 13H 9S 7S 6D")
+	flush, boat := cards("14S 8S"), cards("13D 9H")
+	if compareHands(short.bestHand(flush, board), short.bestHand(boat, board)) != 1 {
+		t.Errorf("full house beat a flush in short deck")
+	}
+	if compareHands(variants["holdem"].bestHand(flush, board), variants["holdem"].bestHand(boat, board)) != -1 {
+		t.Errorf("flush beat a full house in hold'em")
+	}
+
+	// the ordinary rules don't change, A-6-7-8-9 is only ace high
+	if got := standardRules.evaluate(cards("14D 6S 7H 8C 9D")); got.Type != HighCard {
+		t.Errorf("A-6-7-8-9 in hold'em: %s", got.Type)
+	}
+}
+
+func TestShortDeckDeal(t *testing.T) {
+	h := newHand([]Player{
+		{ID: "A", Stack: 100 * Chip},
+		{ID: "B", Stack: 100 * Chip},
+	}, Positions{Dealer: 0, SmallBlind: 0, BigBlind: 1}, Chip, 2*Chip, 0, randomSeed(), variants["shortdeck"])
+	if len(h.deck) != 36 {
+		t.Fatalf("short deck has %d cards, want 36", len(h.deck))
+	}
+	seen := map[Card]bool{}
+	for _, c := range h.deck {
+		if c.Rank == "2" || c.Rank == "3" || c.Rank == "4" || c.Rank == "5" || seen[c] {
+			t.Fatalf("short deck has %s%s", c.Rank, c.Suit)
+		}
+		seen[c] = true
+	}
+
+	r := seatedRoom(t, "1", "2", "3")
+	r.cfg.Game = "shortdeck"
+	r.startNextHandIfReady()
+	if n := len(r.currentHand.deck); n != 36-6 {
+		t.Fatalf("%d cards left after dealing three players, want 30", n)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := r.action(Action{PlayerID: id, Action: "fold"}); err != nil {
+			t.Fatalf("%s fold: %v", id, err)
+		}
+	}
+	if r.frozen != nil || r.handCount != 2 {
+		t.Fatalf("frozen %+v after %d hands", r.frozen, r.handCount)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import "time"
+
+// what everyone at the table can see about the hand in progress
+type HandView struct {
+	Street        string     `json:"street"`
+	Board         []Card     `json:"board"`
+	Pot           Chips      `json:"pot"`
+	Pots          []PotView  `json:"pots"` // main pot first, then side pots
+	ActionSeat    int        `json:"actionSeat"`
+	CurrentBet    Chips      `json:"currentBet"`
+	Betting       string     `json:"betting"`           // "no-limit", "pot-limit" or "fixed-limit"
+	BetSize       Chips      `json:"betSize,omitempty"` // fixed-limit: what a bet or raise adds this street
+	Capped        bool       `json:"capped,omitempty"`  // fixed-limit: no more raises this street
+	Seats         []SeatView `json:"seats"`
+	TimeRemaining float64    `json:"timeRemaining"` // seconds left on the acting player's base clock
+	TimeBank      float64    `json:"timeBank"`      // the acting player's time bank, counts down once it is in use
+	UsingTimeBank bool       `json:"usingTimeBank"`
+}
+
+// a player dealt into the hand, as seen by everyone
+type SeatView struct {
+	Seat     int    `json:"seat"`
+	PlayerID string `json:"playerId"`
+	Stack    Chips  `json:"stack"` // chips behind, not counting what is in the pot
+	Bet      Chips  `json:"bet"`   // put in on this street
+	Folded   bool   `json:"folded"`
+	AllIn    bool   `json:"allIn"`
+}
+
+type PotView struct {
+	Amount Chips `json:"amount"`
+	Seats  []int `json:"seats"` // seats that can win it
+}
+
+// what only the requesting player can see: their cards and what they can do
+type PrivateView struct {
+	PlayerID string   `json:"playerId"`
+	Seat     int      `json:"seat"`
+	Cards    []Card   `json:"cards"`
+	Actions  []string `json:"actions"` // empty unless it is their turn
+	ToCall   Chips    `json:"toCall"`
+	MinRaise Chips    `json:"minRaise"` // smallest total to raise to, 0 if they can't raise
+	MaxRaise Chips    `json:"maxRaise"` // largest total to raise to (all in, the pot limit, or the same as MinRaise in fixed-limit)
+}
+
+// public view of the hand in progress
+func handView(h *Hand, deadline time.Time) *HandView {
+	v := &HandView{
+		Street:     h.currentState.String(),
+		Board:      append([]Card{}, h.board...),
+		Pot:        h.pot,
+		Pots:       []PotView{},
+		ActionSeat: h.Players[h.actionPlayerIndex].Seat,
+		CurrentBet: h.currentBet,
+		Betting:    h.game.Betting,
+		Seats:      []SeatView{},
+	}
+	if h.game.Betting == FixedLimit {
+		v.BetSize = betSize(h)
+		v.Capped = betsCapped(h)
+	}
+	if left := time.Until(deadline).Seconds(); left > 0 {
+		v.TimeRemaining = left
+	}
+	for _, p := range h.Players {
+		v.Seats = append(v.Seats, SeatView{
+			Seat:     p.Seat,
+			PlayerID: p.ID,
+			Stack:    p.Stack,
+			Bet:      p.streetBet,
+			Folded:   p.folded,
+			AllIn:    !p.folded && p.Stack == 0,
+		})
+	}
+	for _, pot := range buildPots(h) {
+		pv := PotView{Amount: pot.Amount, Seats: []int{}}
+		for _, i := range pot.Eligible {
+			pv.Seats = append(pv.Seats, h.Players[i].Seat)
+		}
+		v.Pots = append(v.Pots, pv)
+	}
+	return v
+}
+
+// private view for one player in the hand, nil if they are not dealt in
+func privateView(h *Hand, id string) *PrivateView {
+	i := FindPlayerIndexInHand(h, id)
+	if i < 0 {
+		return nil
+	}
+	p := h.Players[i]
+	v := &PrivateView{
+		PlayerID: p.ID,
+		Seat:     p.Seat,
+		Cards:    append([]Card{}, p.hand...),
+		Actions:  []string{},
+		ToCall:   amountToCall(h, p),
+	}
+	if i == h.actionPlayerIndex && h.currentState != StateOver {
+		v.Actions = append(v.Actions, h.avaliableActions...)
+		if contains(h.avaliableActions, "raise") {
+			minTo, maxTo := raiseBounds(h, p)
+			// can't make a full raise: going all in is the only raise left
+			if minTo > maxTo {
+				minTo = maxTo
+			}
+			v.MinRaise, v.MaxRaise = minTo, maxTo
+		}
+	}
+	return v
+}
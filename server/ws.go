@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// CORS is open for the http api too, so accept any origin
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPingPeriod = 30 * time.Second
+)
+
+// live table events
+// format is ws://localhost:8080/ws?room=1&playerId=2&token=... (playerId and token are optional,
+// together they add that player's hole cards). the token goes in the url because
+// browsers can't set headers on a websocket, nothing else accepts it there
+func (s *Server) wsHandler(w http.ResponseWriter, r *http.Request) {
+	rm, ok := s.roomFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// check the token before upgrading so a bad one gets a normal 401
+	c := &wsClient{playerID: r.URL.Query().Get("playerId"), send: make(chan []byte, 64)}
+	session := ""
+	if c.playerID != "" {
+		if session, ok = s.authorizeToken(w, r.URL.Query().Get("token"), rm.id, c.playerID); !ok {
+			return
+		}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader already wrote the error response
+		return
+	}
+	if resp := rm.send(Command{Kind: "subscribe", Client: c, Session: session}); resp.Err != nil {
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, resp.Err.Error()))
+		conn.Close()
+		return
+	}
+
+	go wsWriter(conn, c)
+
+	// clients don't send anything, reading just tells us when they go away
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	rm.send(Command{Kind: "unsubscribe", Client: c})
+}
+
+// wsWriter sends queued events to the connection until the room closes the send channel
+func wsWriter(conn *websocket.Conn, c *wsClient) {
+	ping := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ping.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
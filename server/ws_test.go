@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketStreamsEvents(t *testing.T) {
+	s := newServer()
+	s.addRoom(RoomConfig{MinStack: 10 * Chip, MaxStack: 100 * Chip, SmallBlind: Chip, BigBlind: 2 * Chip, Seats: 6})
+	srv := httptest.NewServer(withCORS(s.routes()))
+	defer srv.Close()
+
+	token := joinHTTP(t, s, srv.URL, "1")
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?room=1&playerId=1&token=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// someone else's token can't be used to listen in on player 1
+	other := joinHTTP(t, s, srv.URL, "2")
+	bad := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?room=1&playerId=1&token=" + other
+	if _, resp, err := websocket.DefaultDialer.Dial(bad, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial with player 2's token should be refused")
+	}
+
+	for id, tok := range map[string]string{"1": token, "2": other} {
+		if code := postHTTP(t, srv.URL+"/sitInOrOut?room=1&sitIn=true&playerId="+id, tok, ""); code != http.StatusOK {
+			t.Fatalf("sit in %s: %d", id, code)
+		}
+	}
+
+	// read until the hand is dealt and the first player is on the clock
+	seen := map[string]int{}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for seen[EventTimer] == 0 {
+		var e Event
+		if err := conn.ReadJSON(&e); err != nil {
+			t.Fatalf("read: %v (seen %v)", err, seen)
+		}
+		seen[e.Type]++
+		if e.Type == EventCardsDealt && (e.PlayerID != "1" || len(e.Cards) != 2) {
+			b, _ := json.Marshal(e)
+			t.Fatalf("got someone else's cards: %s", b)
+		}
+	}
+
+	for _, typ := range []string{EventSnapshot, EventPlayerJoined, EventSitIn, EventHandStarted, EventAction, EventCardsDealt} {
+		if seen[typ] == 0 {
+			t.Errorf("never got a %s event (seen %v)", typ, seen)
+		}
+	}
+	if seen[EventCardsDealt] != 1 {
+		t.Errorf("got %d cards_dealt events, want only our own", seen[EventCardsDealt])
+	}
+
+	// the deal is followed by the table as player 1 sees it, no need to ask /state
+	for {
+		var e Event
+		if err := conn.ReadJSON(&e); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if e.Type != EventSnapshot {
+			continue
+		}
+		if e.State == nil || e.State.Hand == nil || e.State.You == nil || len(e.State.You.Cards) != 2 {
+			b, _ := json.Marshal(e)
+			t.Fatalf("snapshot after the deal: %s", b)
+		}
+		break
+	}
+}